@@ -0,0 +1,229 @@
+// Package catalog indexes the Landsat scenes found in a directory tree, as delivered band files with their MTL
+// metadata or as converted multi-band images, into a JSON catalogue.
+package catalog
+
+import (
+	"encoding/json"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nordicsense/landsat/dataset"
+	"github.com/nordicsense/landsat/io"
+	"github.com/nordicsense/landsat/product"
+)
+
+// Image attributes of MTL files, stored under the same keys into the metadata of converted images.
+const (
+	cloudCoverKey     = "CLOUD_COVER"
+	cloudCoverLandKey = "CLOUD_COVER_LAND"
+
+	mtlSuffix = "_MTL.json"
+)
+
+var (
+	bandRe  = regexp.MustCompile(`(?i)_B\d+(_VCID_\d)?\.TIF$`)
+	imageRe = regexp.MustCompile(`(?i)\.tiff?$`)
+)
+
+// Scene describes an acquisition and the files found of it. Metadata unknown for lack of MTL files or
+// converted images is omitted.
+type Scene struct {
+	ID             string    `json:"id"`
+	Sensor         string    `json:"sensor"`
+	Satellite      int       `json:"satellite"`
+	Level          string    `json:"level"`
+	Path           int       `json:"path"`
+	Row            int       `json:"row"`
+	Acquired       time.Time `json:"acquired"`
+	Processed      time.Time `json:"processed"`
+	Collection     int       `json:"collection,omitempty"`
+	Tier           string    `json:"tier,omitempty"`
+	CloudCover     *float64  `json:"cloud_cover,omitempty"`
+	CloudCoverLand *float64  `json:"cloud_cover_land,omitempty"`
+	SunElevation   *float64  `json:"sun_elevation,omitempty"`
+	SunAzimuth     *float64  `json:"sun_azimuth,omitempty"`
+	// Metadata is the MTL file.
+	Metadata string `json:"metadata,omitempty"`
+	// Archive is the tar archive of the scene as delivered, holding its bands and MTL file.
+	Archive string `json:"archive,omitempty"`
+	// Bands are the band files as delivered.
+	Bands []string `json:"bands,omitempty"`
+	// Images are the converted multi-band images; single-band products such as class maps are not listed.
+	Images []string `json:"images,omitempty"`
+}
+
+// Catalog lists the scenes found under the root directory ordered by acquisition date and identifier.
+type Catalog struct {
+	Root    string    `json:"root"`
+	Created time.Time `json:"created"`
+	Scenes  []Scene   `json:"scenes"`
+}
+
+// Build indexes the scenes of the local or remote directory tree. Files are assigned to scenes by the product
+// identifier their names start with; hidden files and files without identifier are ignored.
+func Build(root string) (*Catalog, error) {
+	fileNames, err := files(root)
+	if err != nil {
+		return nil, err
+	}
+	scenes := make(map[string]*Scene)
+	for _, fileName := range fileNames {
+		name := path.Base(fileName)
+		id, err := product.ParseID(name)
+		if err != nil {
+			continue
+		}
+		s, ok := scenes[id.String()]
+		if !ok {
+			s = newScene(id)
+			scenes[id.String()] = s
+		}
+		switch {
+		case strings.HasSuffix(name, mtlSuffix):
+			s.Metadata = fileName
+			if err = s.readMTL(path.Dir(fileName), name); err != nil {
+				return nil, err
+			}
+		case io.IsArchive(name):
+			s.Archive = fileName
+			// archives failing to read, such as incomplete downloads, leave the metadata unknown
+			if s.Metadata == "" && s.readMTL(fileName, id.String()+mtlSuffix) == nil {
+				s.Metadata = io.Join(fileName, id.String()+mtlSuffix)
+			}
+		case bandRe.MatchString(name):
+			s.Bands = append(s.Bands, fileName)
+		case imageRe.MatchString(name):
+			s.addImage(fileName)
+		}
+	}
+
+	res := &Catalog{Root: root, Created: time.Now().UTC()}
+	for _, s := range scenes {
+		res.Scenes = append(res.Scenes, *s)
+	}
+	sort.Slice(res.Scenes, func(i, j int) bool {
+		a, b := res.Scenes[i], res.Scenes[j]
+		if !a.Acquired.Equal(b.Acquired) {
+			return a.Acquired.Before(b.Acquired)
+		}
+		return a.ID < b.ID
+	})
+	return res, nil
+}
+
+// files lists the files of the directory tree, leaving out hidden files and directories.
+func files(root string) ([]string, error) {
+	if io.IsRemote(root) {
+		return io.ScanTree(root, `^[^.]`)
+	}
+	var res []string
+	err := filepath.WalkDir(root, func(fileName string, d fs.DirEntry, err error) error {
+		switch {
+		case err != nil:
+			return err
+		case strings.HasPrefix(d.Name(), ".") && fileName != root && d.IsDir():
+			return filepath.SkipDir
+		case !strings.HasPrefix(d.Name(), ".") && !d.IsDir():
+			res = append(res, fileName)
+		}
+		return nil
+	})
+	return res, err
+}
+
+func newScene(id product.ID) *Scene {
+	return &Scene{
+		ID:         id.String(),
+		Sensor:     id.Sensor,
+		Satellite:  id.Satellite,
+		Level:      id.Level,
+		Path:       id.Path,
+		Row:        id.Row,
+		Acquired:   id.Acquired,
+		Processed:  id.Processed,
+		Collection: id.Collection,
+		Tier:       id.Tier,
+	}
+}
+
+// readMTL reads cloud cover and sun position from the image attributes of an MTL file in a directory or tar
+// archive.
+func (s *Scene) readMTL(root, name string) error {
+	data, err := io.ReadFile(root, name)
+	if err != nil {
+		return err
+	}
+	var mtl struct {
+		File struct {
+			Attributes map[string]interface{} `json:"IMAGE_ATTRIBUTES"`
+		} `json:"LANDSAT_METADATA_FILE"`
+	}
+	if err = json.Unmarshal(data, &mtl); err != nil {
+		return err
+	}
+	s.setMetadata(func(key string) (string, bool) {
+		v, ok := mtl.File.Attributes[key].(string)
+		return v, ok
+	})
+	return nil
+}
+
+// addImage lists a converted image and, without MTL file, reads cloud cover and sun position from its metadata.
+// Single-band products such as class maps are not listed, but their metadata are read all the same. Files
+// failing to open are ignored.
+func (s *Scene) addImage(fileName string) {
+	r, err := dataset.OpenMultiBand(fileName)
+	if err != nil {
+		return
+	}
+	defer r.Close()
+	if r.Bands() > 1 {
+		s.Images = append(s.Images, fileName)
+	}
+	if s.Metadata == "" && s.CloudCover == nil {
+		s.setMetadata(r.DatasetParams().MetadataItem)
+	}
+}
+
+func (s *Scene) setMetadata(item func(key string) (string, bool)) {
+	get := func(key string) *float64 {
+		v, ok := item(key)
+		if !ok {
+			return nil
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil
+		}
+		return &f
+	}
+	s.CloudCover = get(cloudCoverKey)
+	s.CloudCoverLand = get(cloudCoverLandKey)
+	s.SunElevation = get(dataset.SunElevationKey)
+	s.SunAzimuth = get(dataset.SunAzimuthKey)
+}
+
+// Load reads a catalogue written by Save, locally or from remote storage.
+func Load(fileName string) (*Catalog, error) {
+	data, err := io.ReadFile(path.Dir(fileName), path.Base(fileName))
+	if err != nil {
+		return nil, err
+	}
+	res := &Catalog{}
+	return res, json.Unmarshal(data, res)
+}
+
+// Save writes the catalogue as indented JSON, locally or to S3.
+func (c *Catalog) Save(fileName string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return io.WriteFile(fileName, data)
+}
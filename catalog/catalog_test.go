@@ -0,0 +1,61 @@
+package catalog
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		fileName := path.Join(root, name)
+		if err := os.MkdirAll(path.Dir(fileName), 0750); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fileName, []byte(content), 0640); err != nil {
+			t.Fatal(err)
+		}
+	}
+	const late = "LT05_L2SP_190011_20090725_20200827_02_T1"
+	const early = "LE07_L2SP_188012_20000726_20200917_02_T1"
+	write(late+"/"+late+"_MTL.json", `{"LANDSAT_METADATA_FILE": {"IMAGE_ATTRIBUTES": {
+		"CLOUD_COVER": "12.50", "SUN_ELEVATION": "45.1", "WRS_PATH": "190"}}}`)
+	write(late+"/"+late+"_SR_B1.TIF", "")
+	write(late+"/"+late+"_SR_B2.TIF", "")
+	write(late+"/"+late+"_ANG.txt", "")
+	write(early+"/"+early+"_ST_B6.TIF", "")
+	write(early+"/.partial."+early+"_SR.tiff", "")
+	write("README.txt", "")
+
+	c, err := Build(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Scenes) != 2 || c.Scenes[0].ID != early || c.Scenes[1].ID != late {
+		t.Fatalf("unexpected scenes %+v", c.Scenes)
+	}
+	s := c.Scenes[1]
+	if s.Satellite != 5 || s.Path != 190 || s.Row != 11 || len(s.Bands) != 2 || s.Metadata == "" {
+		t.Errorf("unexpected scene %+v", s)
+	}
+	if s.CloudCover == nil || *s.CloudCover != 12.5 || s.SunElevation == nil || *s.SunElevation != 45.1 ||
+		s.SunAzimuth != nil {
+		t.Errorf("unexpected metadata %+v", s)
+	}
+	if c.Scenes[0].CloudCover != nil || len(c.Scenes[0].Images) != 0 {
+		t.Errorf("unexpected scene %+v", c.Scenes[0])
+	}
+
+	fileName := path.Join(root, "catalog.json")
+	if err = c.Save(fileName); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := Load(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.Scenes) != 2 || *loaded.Scenes[1].CloudCover != 12.5 || !loaded.Scenes[1].Acquired.Equal(s.Acquired) {
+		t.Errorf("unexpected catalogue %+v", loaded)
+	}
+}
@@ -0,0 +1,128 @@
+package catalog
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nordicsense/landsat/io"
+	"github.com/nordicsense/landsat/product"
+)
+
+// Query selects scenes by acquisition date range, day-of-year range, WRS path/row and cloud cover. Zero values
+// do not restrict the selection.
+type Query struct {
+	// From and To bound the acquisition date, both inclusive.
+	From, To time.Time
+	// FirstDay and LastDay bound the day of year of the acquisition, both inclusive. The range wraps around the
+	// year end if LastDay precedes FirstDay.
+	FirstDay, LastDay int
+	// MaxCloud is the maximum cloud cover in percent; scenes of unknown cloud cover are not selected.
+	MaxCloud *float64
+	// PathRows lists the WRS-2 path/rows selected.
+	PathRows []PathRow
+}
+
+var pathRowRe = regexp.MustCompile(`^(?:(\d{1,3})/(\d{1,3})|(\d{3})(\d{3}))$`)
+
+// PathRow is a WRS-2 path and row.
+type PathRow struct {
+	Path, Row int
+}
+
+// ParsePathRow parses a path/row given as 188/012 or 188012.
+func ParsePathRow(v string) (PathRow, error) {
+	var res PathRow
+	m := pathRowRe.FindStringSubmatch(strings.TrimSpace(v))
+	if m == nil {
+		return res, fmt.Errorf("path/row %s must be given as PPP/RRR", v)
+	}
+	res.Path, _ = strconv.Atoi(m[1] + m[3])
+	res.Row, _ = strconv.Atoi(m[2] + m[4])
+	return res, nil
+}
+
+// Empty tells whether the query selects all scenes.
+func (q *Query) Empty() bool {
+	return q == nil || (q.From.IsZero() && q.To.IsZero() && q.FirstDay == 0 && q.LastDay == 0 && q.MaxCloud == nil &&
+		len(q.PathRows) == 0)
+}
+
+// Matches tells whether the scene is selected.
+func (q *Query) Matches(s Scene) bool {
+	if q.Empty() {
+		return true
+	}
+	if !q.From.IsZero() && s.Acquired.Before(q.From) || !q.To.IsZero() && s.Acquired.After(q.To) {
+		return false
+	}
+	if q.FirstDay > 0 && q.LastDay > 0 {
+		doy := s.Acquired.YearDay()
+		if q.FirstDay <= q.LastDay && (doy < q.FirstDay || doy > q.LastDay) ||
+			q.FirstDay > q.LastDay && doy < q.FirstDay && doy > q.LastDay {
+			return false
+		}
+	}
+	if q.MaxCloud != nil && (s.CloudCover == nil || *s.CloudCover > *q.MaxCloud) {
+		return false
+	}
+	if len(q.PathRows) > 0 {
+		found := false
+		for _, pr := range q.PathRows {
+			found = found || pr.Path == s.Path && pr.Row == s.Row
+		}
+		return found
+	}
+	return true
+}
+
+// Selects tells whether the file, named by the product identifier of its scene, is of a selected scene. Cloud
+// cover is read from the MTL file next to it or in it if it is a tar archive, or from the metadata of converted
+// images.
+func (q *Query) Selects(fileName string) bool {
+	if q.Empty() {
+		return true
+	}
+	s, err := SceneOf(fileName)
+	return err == nil && q.Matches(s)
+}
+
+// SceneOf returns the scene of a file named by its product identifier, with the metadata of the MTL file next
+// to it or in it if it is a tar archive or, without, of the file itself if it is a converted image.
+func SceneOf(fileName string) (Scene, error) {
+	id, err := product.ParseID(fileName)
+	if err != nil {
+		return Scene{}, err
+	}
+	s := newScene(id)
+	mtl := id.String() + mtlSuffix
+	if io.IsArchive(fileName) {
+		s.Archive = fileName
+		s.Metadata = io.Join(fileName, mtl)
+		if err = s.readMTL(fileName, mtl); err != nil {
+			return Scene{}, err
+		}
+	} else if io.Exists(path.Dir(fileName), mtl) {
+		s.Metadata = path.Join(path.Dir(fileName), mtl)
+		if err = s.readMTL(path.Dir(fileName), mtl); err != nil {
+			return Scene{}, err
+		}
+	} else if imageRe.MatchString(fileName) {
+		s.addImage(fileName)
+	}
+	return *s, nil
+}
+
+// Select returns the scenes matching the query.
+func (c *Catalog) Select(q *Query) []Scene {
+	var res []Scene
+	for _, s := range c.Scenes {
+		if q.Matches(s) {
+			res = append(res, s)
+		}
+	}
+	return res
+}
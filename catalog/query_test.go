@@ -0,0 +1,91 @@
+package catalog
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"os"
+	"path"
+	"testing"
+	"time"
+)
+
+func TestMatches(t *testing.T) {
+	cloud := func(v float64) *float64 { return &v }
+	scene := Scene{Path: 188, Row: 12, Acquired: time.Date(1988, 7, 10, 0, 0, 0, 0, time.UTC), CloudCover: cloud(15)}
+
+	pr, err := ParsePathRow("188/012")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, tc := range []struct {
+		q        *Query
+		expected bool
+	}{
+		{nil, true},
+		{&Query{}, true},
+		{&Query{From: time.Date(1985, 6, 1, 0, 0, 0, 0, time.UTC), To: time.Date(1990, 8, 31, 0, 0, 0, 0, time.UTC)}, true},
+		{&Query{From: time.Date(1988, 7, 11, 0, 0, 0, 0, time.UTC)}, false},
+		{&Query{To: time.Date(1988, 7, 10, 0, 0, 0, 0, time.UTC)}, true},
+		{&Query{FirstDay: 170, LastDay: 240}, true},
+		{&Query{FirstDay: 200, LastDay: 240}, false},
+		// wrapping around the year end
+		{&Query{FirstDay: 300, LastDay: 200}, true},
+		{&Query{FirstDay: 300, LastDay: 100}, false},
+		{&Query{MaxCloud: cloud(20)}, true},
+		{&Query{MaxCloud: cloud(10)}, false},
+		{&Query{PathRows: []PathRow{{190, 11}, pr}}, true},
+		{&Query{PathRows: []PathRow{{190, 11}}}, false},
+	} {
+		if actual := tc.q.Matches(scene); actual != tc.expected {
+			t.Errorf("%d: expected %v, found %v", i, tc.expected, actual)
+		}
+	}
+
+	unknown := scene
+	unknown.CloudCover = nil
+	if (&Query{MaxCloud: cloud(100)}).Matches(unknown) {
+		t.Error("expected scenes of unknown cloud cover not to match")
+	}
+	if _, err = ParsePathRow("188-12"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestSceneOfArchive(t *testing.T) {
+	const id = "LT05_L2SP_190011_20090725_20200827_02_T1"
+	fileName := path.Join(t.TempDir(), id+".tar.gz")
+	f, err := os.Create(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range map[string]string{
+		id + "_SR_B1.TIF": "",
+		id + "_MTL.json":  `{"LANDSAT_METADATA_FILE": {"IMAGE_ATTRIBUTES": {"CLOUD_COVER": "7.00"}}}`,
+	} {
+		if err = tw.WriteHeader(&tar.Header{Name: name, Mode: 0640, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range []interface{ Close() error }{tw, gz, f} {
+		if err = c.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s, err := SceneOf(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Archive != fileName || s.CloudCover == nil || *s.CloudCover != 7 {
+		t.Errorf("unexpected scene %+v", s)
+	}
+	cloud := 5.0
+	if (&Query{MaxCloud: &cloud}).Selects(fileName) {
+		t.Error("expected the scene not to be selected")
+	}
+}
@@ -5,17 +5,22 @@ import (
 	"github.com/nordicsense/gdal"
 	"github.com/nordicsense/landsat/classification"
 	"github.com/nordicsense/landsat/dataset"
-	"github.com/vardius/progress-go"
-	"path"
+	"github.com/nordicsense/landsat/logging"
 )
 
-var (
-	TL = dataset.LatLon{7674459, 355738} // sin
-	BR = dataset.LatLon{7339883, 593636}
+const (
+	// OutputName is the file name of the change map written into the output directory.
+	OutputName = "out.tiff"
+	// TransitionsName is the file name of the map of class transitions written into the output directory.
+	TransitionsName = "transitions.tiff"
+	// EncodingKey is the dataset metadata item marking a transitions map and its value encoding.
+	EncodingKey = "CHANGE_ENCODING"
 
-	accepted_mismatch map[int]map[int]bool
+	transitionEncoding = "FROM*100+TO"
 )
 
+var accepted_mismatch map[int]map[int]bool
+
 func init() {
 	accepted_mismatch = map[int]map[int]bool{
 		2: {
@@ -45,7 +50,38 @@ func init() {
 	}
 }
 
-func Collect(fromTiffs, toTiffs []string, tl, br dataset.LatLon, outputDir string) error {
+// Legend maps the values of the change map to names: unchanged pixels keep their class, -1 marks changes.
+func Legend() map[int]string {
+	res := classification.Legend()
+	res[-1] = "changed"
+	return res
+}
+
+// Categories maps the values of the change map to names and colours for embedding into the map.
+func Categories() map[int]dataset.Category {
+	res := classification.Categories()
+	res[-1] = dataset.Category{Name: "changed", Color: [4]uint8{255, 0, 255, 255}}
+	return res
+}
+
+// IsTransitions tells whether dataset metadata describe a transitions map.
+func IsTransitions(dp *dataset.DatasetParams) bool {
+	v, ok := dp.MetadataItem(EncodingKey)
+	return ok && v == transitionEncoding
+}
+
+// Decode splits a value of the transitions map into the from and to classes.
+func Decode(v int) (from, to int) {
+	return v / 100, v % 100
+}
+
+func encode(from, to int) float64 {
+	return float64(from*100 + to)
+}
+
+// Collect writes the change and transitions maps of the area between the top-left and bottom-right corners in
+// projected coordinates. In verbose mode, progress is reported and the pixel counts of class transitions logged.
+func Collect(fromTiffs, toTiffs []string, tl, br dataset.LatLon, outputTiff, transitionsTiff string, verbose bool, output dataset.OutputOptions) error {
 
 	if len(fromTiffs) != 2 || len(toTiffs) != 2 {
 		return fmt.Errorf("incorrect number of _from_ (%d) or _to_ (%d) images, expected 2 each", len(fromTiffs), len(toTiffs))
@@ -74,56 +110,64 @@ func Collect(fromTiffs, toTiffs []string, tl, br dataset.LatLon, outputDir strin
 
 	ip := f0.ImageParams().ToBuilder().Transform(dataset.AffineTransform{tl[1], 30., 0., tl[0], 0., -30.}).Build()
 
+	// the corners and all images are in the same projection, the extent is affine without any reprojection
 	tf := ip.Transform()
-	ll, _ := br.Sin2Degree()
-	nx, ny := tf.LatLon2Pixels(ll)
+	nx, ny := tf.LatLonSin2Pixels(br)
 
 	ip = dataset.ImageParamsBuilder(nx, ny).Transform(tf).DataType(gdal.Int16).Projection(ip.Projection()).NaN(0).Build()
 
-	rp := f0.RasterParams().ToBuilder().Build()
+	rp := f0.RasterParams().ToBuilder().ColorInterp(gdal.CI_Undefined).Categories(Categories()).Build()
 
-	w, err := dataset.NewUniBand(path.Join(outputDir, "out.tiff"), dataset.GTiff,
-		ip, rp, "compress=LZW", "predictor=2")
+	w, err := dataset.NewUniBand(outputTiff, dataset.GTiff,
+		ip, rp, output.CreationOptions(ip.DataType())...)
 	if err != nil {
 		return err
 	}
 	defer w.Close()
 
-	bar := progress.New(0, int64(ny))
-	bar.Start()
-
-	wds := w.BreakGlass().RasterBand(1)
+	tip := ip.ToBuilder().DataType(gdal.UInt16).Build()
+	trp := dataset.RasterParamsBuilder().Description("transition").Build()
+	tw, err := dataset.NewUniBand(transitionsTiff, dataset.GTiff,
+		tip, trp, output.CreationOptions(tip.DataType())...)
+	if err != nil {
+		return err
+	}
+	defer tw.Close()
+	if err = tw.SetDatasetParams(dataset.DatasetParamsBuilder().Metadata(EncodingKey, transitionEncoding).Build()); err != nil {
+		return err
+	}
 
-	dsMap := make(map[dataset.UniBandReader]gdal.RasterBand)
-	dsMap[f0] = f0.BreakGlass().RasterBand(1)
-	dsMap[f1] = f1.BreakGlass().RasterBand(1)
-	dsMap[t0] = t0.BreakGlass().RasterBand(1)
-	dsMap[t1] = t1.BreakGlass().RasterBand(1)
+	bar := logging.NewProgress("change", 0, int64(ny))
+	if verbose {
+		bar.Start()
+	}
 
 	var m [classification.NClasses][classification.NClasses]int
 	for y := 0; y < ny; y++ {
-		ll := tf.Pixels2LatLon(0, y)
+		ll := tf.Pixels2LatLonSin(0, y)
 
 		row1 := make([]float64, nx)
 		row2 := make([]float64, nx)
+		trans1 := make([]float64, nx)
+		trans2 := make([]float64, nx)
 		var err error
 
-		_, yyf := f0.ImageParams().Transform().LatLon2Pixels(ll)
-		_, yyt := t0.ImageParams().Transform().LatLon2Pixels(ll)
+		_, yyf := f0.ImageParams().Transform().LatLonSin2Pixels(ll)
+		_, yyt := t0.ImageParams().Transform().LatLonSin2Pixels(ll)
 		if yyf >= 0 && yyt >= 0 && yyf < f0.ImageParams().YSize() && yyt < t0.ImageParams().YSize() {
-			x0f, _ := tf.LatLon2Pixels(f0.ImageParams().Transform().Pixels2LatLon(0, yyf))
-			x0t, _ := tf.LatLon2Pixels(t0.ImageParams().Transform().Pixels2LatLon(0, yyt))
-			row1, err = merge(ip, f0, t0, x0f, yyf, x0t, yyt, &m, dsMap)
+			x0f, _ := tf.LatLonSin2Pixels(f0.ImageParams().Transform().Pixels2LatLonSin(0, yyf))
+			x0t, _ := tf.LatLonSin2Pixels(t0.ImageParams().Transform().Pixels2LatLonSin(0, yyt))
+			row1, trans1, err = merge(ip, f0, t0, x0f, yyf, x0t, yyt, &m)
 			if err != nil {
 				return err
 			}
 		}
-		_, yyf = f1.ImageParams().Transform().LatLon2Pixels(ll)
-		_, yyt = t1.ImageParams().Transform().LatLon2Pixels(ll)
+		_, yyf = f1.ImageParams().Transform().LatLonSin2Pixels(ll)
+		_, yyt = t1.ImageParams().Transform().LatLonSin2Pixels(ll)
 		if yyf >= 0 && yyt >= 0 && yyf < f1.ImageParams().YSize() && yyt < t1.ImageParams().YSize() {
-			x0f, _ := tf.LatLon2Pixels(f1.ImageParams().Transform().Pixels2LatLon(0, yyf))
-			x0t, _ := tf.LatLon2Pixels(t1.ImageParams().Transform().Pixels2LatLon(0, yyt))
-			row2, err = merge(ip, f1, t1, x0f, yyf, x0t, yyt, &m, dsMap)
+			x0f, _ := tf.LatLonSin2Pixels(f1.ImageParams().Transform().Pixels2LatLonSin(0, yyf))
+			x0t, _ := tf.LatLonSin2Pixels(t1.ImageParams().Transform().Pixels2LatLonSin(0, yyt))
+			row2, trans2, err = merge(ip, f1, t1, x0f, yyf, x0t, yyt, &m)
 			if err != nil {
 				return err
 			}
@@ -131,23 +175,33 @@ func Collect(fromTiffs, toTiffs []string, tl, br dataset.LatLon, outputDir strin
 		for i, v1 := range row1 {
 			if v1 == 0 {
 				row1[i] = row2[i]
+				trans1[i] = trans2[i]
 			}
 		}
-		if err = wds.IO(gdal.Write, 0, y, nx, 1, row1, nx, 1, 0, 0); err != nil {
+		if err = dataset.WriteTyped(w, 0, y, dataset.Box{0, 0, nx, 1}, row1); err != nil {
 			return err
 		}
-		bar.Advance(1)
+		if err = dataset.WriteTyped(tw, 0, y, dataset.Box{0, 0, nx, 1}, trans1); err != nil {
+			return err
+		}
+		if verbose {
+			bar.Advance(1)
+		}
+	}
+	if verbose {
+		bar.Stop()
+		legend := classification.Legend()
+		for from, counts := range m {
+			logging.Info("transitions", "from", legend[from+1], "counts", counts)
+		}
 	}
-	bar.Stop()
-
-	fmt.Println(m)
 	return nil
 }
 
-func merge(ip *dataset.ImageParams, f0 dataset.UniBandReader, t0 dataset.UniBandReader, x0f, yyf, x0t, yyt int, m *[classification.NClasses][classification.NClasses]int, dsMap map[dataset.UniBandReader]gdal.RasterBand) ([]float64, error) {
+func merge(ip *dataset.ImageParams, f0 dataset.UniBandReader, t0 dataset.UniBandReader, x0f, yyf, x0t, yyt int, m *[classification.NClasses][classification.NClasses]int) ([]float64, []float64, error) {
 	row := make([]float64, f0.ImageParams().XSize())
-	if err := dsMap[f0].IO(gdal.Read, 0, yyf, f0.ImageParams().XSize(), 1, row, f0.ImageParams().XSize(), 1, 0, 0); err != nil {
-		return nil, err
+	if err := dataset.ReadTyped(f0, 0, yyf, dataset.Box{0, 0, f0.ImageParams().XSize(), 1}, row); err != nil {
+		return nil, nil, err
 	}
 	fRow := make([]float64, ip.XSize())
 	for i, v := range row {
@@ -160,8 +214,8 @@ func merge(ip *dataset.ImageParams, f0 dataset.UniBandReader, t0 dataset.UniBand
 		}
 	}
 	row = make([]float64, t0.ImageParams().XSize())
-	if err := dsMap[t0].IO(gdal.Read, 0, yyt, t0.ImageParams().XSize(), 1, row, t0.ImageParams().XSize(), 1, 0, 0); err != nil {
-		return nil, err
+	if err := dataset.ReadTyped(t0, 0, yyt, dataset.Box{0, 0, t0.ImageParams().XSize(), 1}, row); err != nil {
+		return nil, nil, err
 	}
 	tRow := make([]float64, ip.XSize())
 	for i, v := range row {
@@ -175,6 +229,7 @@ func merge(ip *dataset.ImageParams, f0 dataset.UniBandReader, t0 dataset.UniBand
 	}
 
 	row = make([]float64, ip.XSize())
+	trans := make([]float64, ip.XSize())
 	for i, fv := range fRow {
 		tv := tRow[i]
 		tvi := int(tv)
@@ -184,6 +239,7 @@ func merge(ip *dataset.ImageParams, f0 dataset.UniBandReader, t0 dataset.UniBand
 				tvi = fvi
 				tv = fv
 			}
+			trans[i] = encode(fvi, tvi)
 			if fvi == tvi {
 				row[i] = fv
 			} else {
@@ -192,5 +248,5 @@ func merge(ip *dataset.ImageParams, f0 dataset.UniBandReader, t0 dataset.UniBand
 			m[fvi-1][tvi-1]++
 		}
 	}
-	return row, nil
+	return row, trans, nil
 }
@@ -69,14 +69,16 @@ func readTestingSet(dataFile string) ([]classification.Observation, []int, error
 		if err != nil {
 			log.Fatal(err)
 		}
-		var (
-			o  int
-			vv [data.NVars]float64
-		)
+		var o int
+		vv := make(classification.Observation, data.NVars)
 		for i, v := range rec {
 			if i == 0 {
 				continue
 			}
+			if i >= 2+data.NVars {
+				// provenance columns
+				break
+			}
 			if i == 1 {
 				o, err = strconv.Atoi(v)
 				if err != nil {
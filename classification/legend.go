@@ -0,0 +1,36 @@
+package classification
+
+import "github.com/nordicsense/landsat/dataset"
+
+// classColors defines the RGBA colours of classes in classification maps.
+var classColors = map[string][4]uint8{
+	"cloud":       {255, 255, 255, 255},
+	"water":       {31, 120, 180, 255},
+	"water-dam":   {166, 206, 227, 255},
+	"non-veg":     {150, 150, 150, 255},
+	"burnt":       {140, 45, 4, 255},
+	"dwarf-shrub": {178, 223, 138, 255},
+	"wetland":     {102, 194, 165, 255},
+	"pine":        {51, 160, 44, 255},
+	"spruce":      {0, 90, 50, 255},
+	"deciduous":   {217, 240, 106, 255},
+	"veg-tundra":  {223, 194, 125, 255},
+}
+
+// Legend maps the values of the classification maps to class names, 0 stands for no data.
+func Legend() map[int]string {
+	res := make(map[int]string)
+	for id, name := range ClassIdToName {
+		res[id+1] = name
+	}
+	return res
+}
+
+// Categories maps the values of the classification maps to class names and colours for embedding into maps.
+func Categories() map[int]dataset.Category {
+	res := make(map[int]dataset.Category)
+	for v, name := range Legend() {
+		res[v] = dataset.Category{Name: name, Color: classColors[name]}
+	}
+	return res
+}
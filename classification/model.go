@@ -3,7 +3,6 @@ package classification
 import (
 	"fmt"
 	
-	"github.com/nordicsense/landsat/data"
 	tf "github.com/tensorflow/tensorflow/tensorflow/go"
 )
 
@@ -13,7 +12,8 @@ const (
 	modelOutputOp   = "StatefulPartitionedCall"
 )
 
-type Observation [data.NVars]float64
+// Observation is the feature vector of a pixel, of a single scene or temporal over a season.
+type Observation []float64
 
 func LoadModel(name string) (*Model, error) {
 	model, err := tf.LoadSavedModel(name, []string{defaultModelTag}, nil)
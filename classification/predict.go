@@ -3,16 +3,22 @@ package classification
 import (
 	"fmt"
 	"math"
-	"os"
 
 	"github.com/nordicsense/gdal"
 	"github.com/nordicsense/landsat/data"
 	"github.com/nordicsense/landsat/dataset"
-	"github.com/vardius/progress-go"
+	"github.com/nordicsense/landsat/logging"
+	"github.com/nordicsense/landsat/product"
 )
 
-func Predict(modelDir, inputTiff, outputTiff string, minx, maxx, miny, maxy int, landsatId int, skip, verbose bool) error {
-	if _, err := os.Stat(outputTiff); skip && err == nil {
+// checkpointRows is the number of rows after which progress is recorded to resume from.
+const checkpointRows = 256
+
+// Predict classifies the pixel window of a multi-band image, clipped to the image, into a class map of the
+// window extent. An incomplete class map of the same window left by an interrupted run is resumed after its last
+// checkpoint.
+func Predict(modelDir, inputTiff, outputTiff string, window dataset.Box, landsatId int, skip, verbose bool, output dataset.OutputOptions) error {
+	if skip && dataset.UpToDate(outputTiff, inputTiff, modelDir) {
 		return nil
 	}
 	model, err := LoadModel(modelDir)
@@ -20,96 +26,168 @@ func Predict(modelDir, inputTiff, outputTiff string, minx, maxx, miny, maxy int,
 		return err
 	}
 	defer model.Close()
+	return model.Classify(inputTiff, outputTiff, window, landsatId, verbose, output)
+}
 
+// Classify predicts the class map of an image as Predict does, with the model loaded once for many images. Images
+// may be classified concurrently.
+func (m *Model) Classify(inputTiff, outputTiff string, window dataset.Box, landsatId int, verbose bool, output dataset.OutputOptions) error {
 	r, err := dataset.OpenMultiBand(inputTiff)
 	if err != nil {
 		return err
 	}
 	defer r.Close()
 
-	// ugly performance workaround
-	ds := r.Reader(1).BreakGlass()
+	// derive the sensor from the input metadata unless given explicitly
+	if landsatId <= 0 {
+		var ok bool
+		if landsatId, ok = r.DatasetParams().LandsatId(); !ok {
+			return fmt.Errorf("cannot determine Landsat series Id from metadata of %s", inputTiff)
+		}
+	}
+	return m.classify([]dataset.MultiBandReader{r}, []int{landsatId}, nil, outputTiff, window, verbose, output)
+}
 
-	ip := r.ImageParams().ToBuilder().DataType(gdal.Byte).NaN(0.).Build()
-	rp := r.Reader(1).RasterParams().ToBuilder().Offset(0.).Scale(1.).Build()
+// ClassifySeason predicts the class map of co-registered images of a season, see data.Season, from the temporal
+// features of their pixels. The first image gives the grid and the metadata of the class map.
+func (m *Model) ClassifySeason(inputTiffs []string, outputTiff string, window dataset.Box, verbose bool, output dataset.OutputOptions) error {
+	var (
+		rs   []dataset.MultiBandReader
+		ids  []int
+		days []float64
+	)
+	defer func() {
+		for _, r := range rs {
+			r.Close()
+		}
+	}()
+	for _, inputTiff := range inputTiffs {
+		id, err := product.ParseID(inputTiff)
+		if err != nil {
+			return err
+		}
+		r, err := dataset.OpenMultiBand(inputTiff)
+		if err != nil {
+			return err
+		}
+		rs = append(rs, r)
+		if p, ip := r.ImageParams(), rs[0].ImageParams(); p.XSize() != ip.XSize() || p.YSize() != ip.YSize() || p.Transform() != ip.Transform() {
+			return fmt.Errorf("%s is not co-registered with %s", inputTiff, inputTiffs[0])
+		}
+		ids = append(ids, id.Satellite)
+		days = append(days, float64(id.Acquired.YearDay()))
+	}
+	if len(rs) == 0 {
+		return fmt.Errorf("no images of the season")
+	}
+	return m.classify(rs, ids, days, outputTiff, window, verbose, output)
+}
+
+// classify predicts the class map of the images of the Landsat series, from the features of the single image
+// without days of acquisition, from their temporal features otherwise.
+func (m *Model) classify(rs []dataset.MultiBandReader, landsatIds []int, days []float64, outputTiff string, window dataset.Box, verbose bool, output dataset.OutputOptions) error {
+	r := rs[0]
+	if window = r.ImageParams().Clip(window); window[2] == 0 || window[3] == 0 {
+		return fmt.Errorf("window %v does not overlap with the image", window)
+	}
+	ip := r.ImageParams().Subset(window).ToBuilder().DataType(gdal.Byte).NaN(0.).Build()
+	rp := r.Reader(1).RasterParams().ToBuilder().Offset(0.).Scale(1.).
+		Description("class").Categories(Categories()).Build()
 
-	w, err := dataset.NewUniBand(outputTiff, dataset.GTiff, ip, rp, "compress=LZW", "predictor=2")
+	w, done, err := dataset.Resume(outputTiff, ip)
 	if err != nil {
 		return err
 	}
+	if w == nil {
+		if w, err = dataset.NewUniBand(outputTiff, dataset.GTiff, ip, rp, output.CreationOptions(ip.DataType())...); err != nil {
+			return err
+		}
+		// carry acquisition date, sensor etc. over to the classification map
+		if err = w.SetDatasetParams(r.DatasetParams().Inheritable()); err != nil {
+			w.Close()
+			return err
+		}
+	}
 	defer w.Close()
 
-	wds := w.BreakGlass().RasterBand(1)
-
-	nx := r.ImageParams().XSize()
-	if minx < 0 {
-		minx = 0
-	}
-	if maxx > nx {
-		maxx = nx
-	}
-	dx := maxx - minx
-	ny := r.ImageParams().YSize()
-	if miny < 0 {
-		miny = 0
-	}
-	if maxy > ny {
-		maxy = ny
-	}
+	minx, miny := window[0], window[1]
+	dx := window[2]
+	maxx, maxy := minx+dx, miny+window[3]
 
-	bar := progress.New(int64(miny), int64(maxy))
+	bar := logging.NewProgress("predict", int64(miny), int64(maxy))
 	if verbose {
 		bar.Start()
+		bar.Advance(int64(done))
 	}
 
-	var rrs [7][]float64
-	for y := miny; y < maxy && y < ny; y++ {
+	rrs := make([][7][]float64, len(rs))
+	for i := range rrs {
 		for band := 0; band < 7; band++ {
-			rrs[band] = make([]float64, dx)
-			if err = ds.RasterBand(band+1).IO(gdal.Read, minx, y, dx, 1, rrs[band], dx, 1, 0, 0); err != nil {
-				return err
+			rrs[i][band] = make([]float64, dx)
+		}
+	}
+	nVars := data.NVars
+	if days != nil {
+		nVars = data.NTemporalVars
+	}
+	row := make([]uint8, dx)
+	for y := miny + done; y < maxy; y++ {
+		for i, r := range rs {
+			for band := 0; band < 7; band++ {
+				if err = r.Reader(band+1).ReadBlockInto(minx, y, dataset.Box{0, 0, dx, 1}, rrs[i][band]); err != nil {
+					return err
+				}
 			}
 		}
 		var obs []Observation
 		var skips []bool
 		for x := minx; x < maxx; x++ {
-			xx := make([]float64, 7)
+			series := make([][]float64, len(rs))
 			skip := false
-			for band := 0; band < 7; band++ {
-				v := rrs[band][x-minx]
-				if math.IsNaN(v) {
-					skip = true
+			for i := range rs {
+				xx := make([]float64, 7)
+				for band := 0; band < 7; band++ {
+					v := rrs[i][band][x-minx]
+					if math.IsNaN(v) {
+						skip = true
+					}
+					xx[band] = v
 				}
-				xx[band] = v
+				series[i] = data.Transform(xx, landsatIds[i])
 			}
-			xxt := data.Transform(xx, landsatId)
-			if len(xxt) != data.NVars {
-				return fmt.Errorf("expected %d input variables, found %d", data.NVars, len(xxt))
+			xxt := series[0]
+			if days != nil {
+				// scenes with NaN values are left out of temporal features
+				xxt = data.Temporal(series, days)
+				skip = math.IsNaN(xxt[0])
 			}
-			var xxo Observation
-			for i, v := range xxt {
-				xxo[i] = v
+			if len(xxt) != nVars {
+				return fmt.Errorf("expected %d input variables, found %d", nVars, len(xxt))
 			}
-			obs = append(obs, xxo)
+			obs = append(obs, xxt)
 			skips = append(skips, skip)
 		}
-		res, err := model.Predict(obs)
+		res, err := m.Predict(obs)
 		if err != nil {
 			return err
 		}
-		row := make([]int8, len(res))
 		for i := range res {
 			if skips[i] {
 				row[i] = 0
 			} else {
-				row[i] = int8(res[i] + 1)
+				row[i] = uint8(res[i] + 1)
 			}
 		}
 
-		err = wds.IO(gdal.Write, minx, y, dx, 1, row, dx, 1, 0, 0)
+		err = dataset.WriteTyped(w, 0, y-miny, dataset.Box{0, 0, dx, 1}, row)
 		if err != nil {
 			return err
 		}
+		if rows := y - miny + 1; rows%checkpointRows == 0 || y == maxy-1 {
+			if err = dataset.Checkpoint(w, rows); err != nil {
+				return err
+			}
+		}
 		if verbose {
 			bar.Advance(1)
 		}
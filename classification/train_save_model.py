@@ -8,12 +8,16 @@ nClasses = 11
 
 root = os.environ.get("RESULTS_DIR")
 
+# the feature columns, of a single scene or temporal, lie between the class and the provenance columns
+nonFeatures = ['clazz', 'clazzid', 'label', 'source', 'image', 'acquired', 'x', 'y', 'lat', 'lon', 'weight', 'synthetic']
+
 df = pd.read_csv(root + '/trainingdata/trainingdata.csv')
-x = df.drop(['clazz', 'clazzid'], axis=1)
+x = df.drop(nonFeatures, axis=1)
 y = df['clazzid']
+w = df['weight']
 
 df = pd.read_csv(root + '/trainingdata/trainingdata-test.csv')
-x_test = df.drop(['clazz', 'clazzid'], axis=1)
+x_test = df.drop(nonFeatures, axis=1)
 y_test = df['clazzid']
 
 tf.random.set_seed(42)
@@ -30,7 +34,7 @@ model.compile(
     metrics=['accuracy']
 )
 
-model.fit(x, y, epochs=10)
+model.fit(x, y, sample_weight=w, epochs=10)
 model.evaluate(x_test, y_test, verbose=2)
 
 
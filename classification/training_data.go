@@ -1,12 +1,16 @@
 package classification
 
 import (
+	"encoding/json"
 	"fmt"
-	"log"
 	"math"
 	"math/rand"
 
+	"github.com/nordicsense/landsat/catalog"
 	"github.com/nordicsense/landsat/data"
+	"github.com/nordicsense/landsat/io"
+	"github.com/nordicsense/landsat/logging"
+	"github.com/nordicsense/landsat/product"
 )
 
 type classIdMap struct {
@@ -73,7 +77,6 @@ var (
 		"LT05_L1TP_190012_19930713": true,
 	}
 
-	r             *rand.Rand
 	ClassNameToId map[string]int
 	ClassIdToName map[int]string
 )
@@ -81,7 +84,6 @@ var (
 const NClasses = 11
 
 func init() {
-	r = rand.New(rand.NewSource(42))
 	ClassNameToId = make(map[string]int)
 	ClassIdToName = make(map[int]string)
 	for _, m := range mapping {
@@ -93,26 +95,90 @@ func init() {
 	}
 }
 
-const (
-	trainFraction = 0.8
-	clazzSize     = 40000
-	testSize      = 3000
-)
+// TrainingOptions configure the output of training data.
+type TrainingOptions struct {
+	// Format is csv, parquet or npz.
+	Format string
+	// Sampling configures the pixels sampled around field data coordinates.
+	Sampling data.SamplingOptions
+	// Split configures the split into training and test data.
+	Split data.SplitOptions
+	// Folds is the number of cross-validation folds written instead of a single split, if more than 1.
+	Folds int
+	// Balance configures the balancing of classes in training data, test data is never balanced.
+	Balance data.BalanceOptions
+	// Inspect writes the samples flagged by quality control into a review report, also done if they are
+	// excluded.
+	Inspect bool
+	// QC configures the quality control of samples.
+	QC data.QCOptions
+}
+
+// DefaultTrainingOptions returns CSV output of the default split without balancing or quality control.
+func DefaultTrainingOptions() TrainingOptions {
+	qc := data.DefaultQCOptions()
+	qc.CloudClazz = ClassIdToName[0]
+	return TrainingOptions{Format: data.FormatCSV, Sampling: data.DefaultSamplingOptions(), Split: data.DefaultSplitOptions(),
+		Balance: data.DefaultBalanceOptions(), QC: qc}
+}
+
+// trainingSummary is the JSON summary of the training data written, with per-class counts.
+type trainingSummary struct {
+	Format  string                     `json:"format"`
+	Split   string                     `json:"split"`
+	Balance string                     `json:"balance"`
+	Seed    int64                      `json:"seed"`
+	Train   map[string]data.ClazzCount `json:"train"`
+	Test    map[string]data.ClazzCount `json:"test"`
+}
 
-func CollectTrainingData(tabPath, imgPath, csvPath, imgPattern string) error {
+// CollectTrainingData writes training and test data of the field data coordinates in the images of the scenes
+// selected by the query, with cross-validation folds into files numbered from 1, and their per-class counts into
+// a JSON summary. Samples flagged by quality control are written into a review report. Without query, the
+// default scenes are used.
+func CollectTrainingData(tabPath, imgPath, pathOut, imgPattern string, query *catalog.Query, opts TrainingOptions) error {
 	coord, err := data.CollectCoordinates(tabPath)
 	if err != nil {
 		return err
 	}
-	recs, err := data.TrainingData(imgPath, imgPattern, coord, images, convert)
+	candidates := images
+	if !query.Empty() {
+		// the query selects among all scenes of the field data
+		candidates = nil
+	}
+	recs, err := data.TrainingData(imgPath, imgPattern, coord, candidates, query.Selects, opts.Sampling, convert)
 	if err != nil {
 		return err
 	}
-	train, test := data.Subsample(recs, ClassNameToId, clazzSize, testSize, r, trainFraction)
-	if err = data.DumpCSV(csvPath+".csv", train, ClassNameToId); err != nil {
+	if opts.Inspect || opts.QC.Exclude {
+		flags := data.Inspect(recs, opts.QC)
+		if err = data.DumpFlags(pathOut+"-qc.csv", flags); err != nil {
+			return err
+		}
+		logging.Info("quality control", "output", pathOut+"-qc.csv", "flagged", len(flags), "excluded", opts.QC.Exclude)
+		if opts.QC.Exclude {
+			recs = data.Exclude(recs, flags)
+		}
+	}
+	r := rand.New(rand.NewSource(opts.Split.Seed))
+	if opts.Folds > 1 {
+		for i, fold := range data.KFold(recs, ClassNameToId, opts.Split, opts.Folds) {
+			train := data.Balance(fold.Train, ClassNameToId, opts.Balance, r)
+			if err = dumpTrainingData(fmt.Sprintf("%s-fold%d", pathOut, i+1), train, fold.Test, opts); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	train, test := data.Split(recs, ClassNameToId, opts.Split)
+	return dumpTrainingData(pathOut, data.Balance(train, ClassNameToId, opts.Balance, r), test, opts)
+}
+
+func dumpTrainingData(pathOut string, train, test []data.Record, opts TrainingOptions) error {
+	if err := data.Dump(pathOut+"."+opts.Format, train, ClassNameToId); err != nil {
 		return err
 	}
-	if err = data.DumpCSV(csvPath+"-test.csv", test, ClassNameToId); err != nil {
+	if err := data.Dump(pathOut+"-test."+opts.Format, test, ClassNameToId); err != nil {
 		return err
 	}
 
@@ -121,15 +187,28 @@ func CollectTrainingData(tabPath, imgPath, csvPath, imgPattern string) error {
 		j := ClassNameToId[r.Clazz]
 		stats[j]++
 	}
-	log.Println("training stats:", stats)
+	logging.Info("training stats", "output", pathOut, "counts", stats)
 
 	stats = make([]int, NClasses)
 	for _, r := range test {
 		j := ClassNameToId[r.Clazz]
 		stats[j]++
 	}
-	log.Println("testing stats:", stats)
-	return nil
+	logging.Info("testing stats", "output", pathOut, "counts", stats)
+
+	summary := trainingSummary{
+		Format:  opts.Format,
+		Split:   opts.Split.Strategy,
+		Balance: opts.Balance.Strategy,
+		Seed:    opts.Split.Seed,
+		Train:   data.Counts(train, ClassNameToId),
+		Test:    data.Counts(test, ClassNameToId),
+	}
+	bytes, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return err
+	}
+	return io.WriteFile(pathOut+"-summary.json", bytes)
 }
 
 func convert(im string, clazz string, xx []float64) (string, []float64, bool) {
@@ -138,20 +217,14 @@ func convert(im string, clazz string, xx []float64) (string, []float64, bool) {
 	if !ok {
 		return "", nil, false
 	}
-	if !images[im] {
-		return "", nil, false
-	}
 	for _, x := range xx {
 		if math.IsNaN(x) {
 			return "", nil, false
 		}
 	}
-	landsatId := 7
-	switch im[0:5] {
-	case "LT05":
-		landsatId = 5
-	case "LC08":
-		landsatId = 8
+	id, err := product.ParseID(im)
+	if err != nil {
+		return "", nil, false
 	}
-	return newClazz.clazz, data.Transform(xx, landsatId), true
+	return newClazz.clazz, data.Transform(xx, id.Satellite), true
 }
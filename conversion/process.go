@@ -1,29 +1,41 @@
 package conversion
 
 import (
-	"errors"
 	"fmt"
 	"math"
-	"os"
 	"path"
+	"path/filepath"
 	"strconv"
-	"strings"
 
 	"github.com/nordicsense/gdal"
 	"github.com/nordicsense/landsat/dataset"
-	"github.com/vardius/progress-go"
+	"github.com/nordicsense/landsat/io"
+	"github.com/nordicsense/landsat/logging"
+	"github.com/nordicsense/landsat/product"
 )
 
-func MergeAndApply(pathIn, prefix string, pathOut string, l1, skip, verbose bool, options ...string) error {
+// MergeAndApply merges the bands of the product in the directory or tar archive into a multi-band image of
+// reflectances. Bands in archives are read through the GDAL virtual file system without extracting them.
+func MergeAndApply(pathIn, prefix string, fo string, l1, skip, verbose bool, output dataset.OutputOptions) error {
 	var (
 		err error
-		fo  = path.Join(pathOut, prefix+".tiff")
 		w   dataset.MultiBandWriter
 		im  dataset.ImageMetadata
 		buf []float64
 	)
 
-	if _, err := os.Stat(fo); skip && err == nil {
+	// all files of the scene, e.g. bands and MTL, share the product identifier
+	id, err := product.ParseID(prefix)
+	if err != nil {
+		return err
+	}
+	sceneFNames := []string{pathIn}
+	if !io.IsArchive(pathIn) {
+		if sceneFNames, err = filepath.Glob(path.Join(pathIn, id.String()+"*")); err != nil {
+			return err
+		}
+	}
+	if skip && dataset.UpToDate(fo, sceneFNames...) {
 		return nil
 	}
 
@@ -31,21 +43,20 @@ func MergeAndApply(pathIn, prefix string, pathOut string, l1, skip, verbose bool
 		return err
 	}
 
-	bar := progress.New(0, 7)
+	bar := logging.NewProgress("convert", 0, 7)
 	if verbose {
 		bar.Start()
 	}
 
 	for band := 1; band <= 7; band++ {
-		fi := path.Join(pathIn, prefix+"_B"+strconv.Itoa(band)+".TIF")
-		if band == 6 {
-			if _, err := os.Stat(fi); errors.Is(err, os.ErrNotExist) {
-				fi = path.Join(pathIn, strings.Replace(prefix, "SR", "ST", 1)+"_B6.TIF")
-				if _, err := os.Stat(fi); errors.Is(err, os.ErrNotExist) {
-					fi = path.Join(pathIn, prefix+"_B6_VCID_1.TIF")
-				}
+		fi := prefix + "_B" + strconv.Itoa(band) + ".TIF"
+		if band == 6 && !io.Exists(pathIn, fi) {
+			fi = id.String() + "_ST_B6.TIF"
+			if !io.Exists(pathIn, fi) {
+				fi = prefix + "_B6_VCID_1.TIF"
 			}
 		}
+		fi = io.Join(pathIn, fi)
 		var r dataset.UniBandReader
 		if r, err = dataset.OpenUniBand(fi); err != nil {
 			if w != nil {
@@ -60,19 +71,12 @@ func MergeAndApply(pathIn, prefix string, pathOut string, l1, skip, verbose bool
 		// https://www.gisagmaps.com/landsat-8-atco/
 		ip := r.ImageParams().ToBuilder().DataType(gdal.Float32).NaN(math.NaN()).Build()
 		if w == nil {
-			if w, err = dataset.NewMultiBand(fo, dataset.GTiff, 7, ip, options...); err != nil {
+			if w, err = dataset.NewMultiBand(fo, dataset.GTiff, 7, ip, output.CreationOptions(ip.DataType())...); err != nil {
 				r.Close()
 				break
 			}
-			// This hacks into the metadata of the multilayered image, which is not supported by dataset API
-			ds := w.Writer(band).BreakGlass()
 			// ignore errors setting these metadata
-			_ = ds.SetMetadataItem("DATE", im.Date.Format("2006-01-02"), "")
-			_ = ds.SetMetadataItem("SUN_ELEVATION", format(im.SunElevation), "")
-			_ = ds.SetMetadataItem("SUN_AZIMUTH", format(im.SunAzimuth), "")
-			for k, v := range im.Aux {
-				_ = ds.SetMetadataItem(k, v, "")
-			}
+			_ = w.SetDatasetParams(im.DatasetParams().Inheritable())
 		}
 
 		box := dataset.Box{0, 0, ip.XSize(), ip.YSize()}
@@ -109,7 +113,7 @@ func MergeAndApply(pathIn, prefix string, pathOut string, l1, skip, verbose bool
 					dist[j]++
 				}
 			}
-			rpb := r.RasterParams().ToBuilder().Scale(1.0).Offset(0.0)
+			rpb := r.RasterParams().ToBuilder().Scale(1.0).Offset(0.0).Description("B" + strconv.Itoa(band))
 
 			if l1 {
 				rpb = rpb.
@@ -0,0 +1,218 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+// Strategies of balancing the classes of training data.
+const (
+	// BalanceNone keeps the samples as they are.
+	BalanceNone = "none"
+	// BalanceUndersample reduces every class to the samples of the smallest one, selected at random.
+	BalanceUndersample = "undersample"
+	// BalanceOversample grows every class to the samples of the largest one by copies of random samples with
+	// gaussian noise added.
+	BalanceOversample = "oversample"
+	// BalanceSMOTE grows every class to the samples of the largest one by synthetic samples interpolated between
+	// a random sample and one of its nearest neighbours of the class in feature space.
+	BalanceSMOTE = "smote"
+	// BalanceWeights keeps the samples and weighs them inversely to the size of their class.
+	BalanceWeights = "weights"
+)
+
+// BalanceOptions configure the balancing of classes.
+type BalanceOptions struct {
+	// Strategy is one of the balancing strategies, none if empty.
+	Strategy string
+	// Jitter is the standard deviation of the noise added to oversampled copies relative to the standard
+	// deviation of the feature within the class.
+	Jitter float64
+	// Neighbours is the number of nearest neighbours synthetic samples are interpolated towards.
+	Neighbours int
+}
+
+// DefaultBalanceOptions returns no balancing, with a jitter of 0.1 and 5 neighbours if selected.
+func DefaultBalanceOptions() BalanceOptions {
+	return BalanceOptions{Strategy: BalanceNone, Jitter: 0.1, Neighbours: 5}
+}
+
+// Validate checks the strategy and the numeric options.
+func (o BalanceOptions) Validate() error {
+	switch o.Strategy {
+	case "", BalanceNone, BalanceUndersample, BalanceOversample, BalanceSMOTE, BalanceWeights:
+	default:
+		return fmt.Errorf("unknown balancing strategy %s, expected none, undersample, oversample, smote or weights", o.Strategy)
+	}
+	if o.Jitter < 0 || o.Neighbours < 1 {
+		return fmt.Errorf("jitter must not be negative and neighbours must be positive")
+	}
+	return nil
+}
+
+// Balance balances the classes of the samples. Samples added are marked synthetic and carry the provenance of
+// the sample they derive from.
+func Balance(recs []Record, clazzId map[string]int, opts BalanceOptions, r *rand.Rand) []Record {
+	if opts.Strategy == "" || opts.Strategy == BalanceNone {
+		return recs
+	}
+	byClazz := make(map[string][]Record)
+	for _, rec := range recs {
+		byClazz[rec.Clazz] = append(byClazz[rec.Clazz], rec)
+	}
+	var clazzes []string
+	for clazz := range byClazz {
+		clazzes = append(clazzes, clazz)
+	}
+	sort.Slice(clazzes, func(i, j int) bool { return clazzId[clazzes[i]] < clazzId[clazzes[j]] })
+	minSize, maxSize := len(recs), 0
+	for _, xx := range byClazz {
+		if len(xx) < minSize {
+			minSize = len(xx)
+		}
+		if len(xx) > maxSize {
+			maxSize = len(xx)
+		}
+	}
+
+	var res []Record
+	for _, clazz := range clazzes {
+		xx := byClazz[clazz]
+		switch opts.Strategy {
+		case BalanceUndersample:
+			for _, i := range r.Perm(len(xx))[:minSize] {
+				res = append(res, xx[i])
+			}
+			continue
+		case BalanceOversample:
+			xx = append(xx, oversample(xx, maxSize-len(xx), opts.Jitter, r)...)
+		case BalanceSMOTE:
+			xx = append(xx, smote(xx, maxSize-len(xx), opts.Neighbours, r)...)
+		case BalanceWeights:
+			// as n_samples / (n_classes * n_samples_of_class) so that the weights sum up to the samples
+			weight := float64(len(recs)) / float64(len(byClazz)*len(xx))
+			for i := range xx {
+				xx[i].Weight = weight
+			}
+		}
+		res = append(res, xx...)
+	}
+	return res
+}
+
+// oversample returns n copies of random samples with gaussian noise of jitter times the standard deviation of
+// the features.
+func oversample(recs []Record, n int, jitter float64, r *rand.Rand) []Record {
+	if n <= 0 || len(recs) == 0 {
+		return nil
+	}
+	sd := make([]float64, len(recs[0].Data))
+	for j := range sd {
+		var sum, sum2 float64
+		for _, rec := range recs {
+			sum += rec.Data[j]
+			sum2 += rec.Data[j] * rec.Data[j]
+		}
+		mean := sum / float64(len(recs))
+		sd[j] = math.Sqrt(math.Max(sum2/float64(len(recs))-mean*mean, 0))
+	}
+	res := make([]Record, n)
+	for i := range res {
+		res[i] = synthetic(recs[r.Intn(len(recs))])
+		for j := range res[i].Data {
+			res[i].Data[j] += r.NormFloat64() * jitter * sd[j]
+		}
+	}
+	return res
+}
+
+// smote returns n samples interpolated at random between a random sample and one of its k nearest neighbours.
+func smote(recs []Record, n, k int, r *rand.Rand) []Record {
+	if n <= 0 || len(recs) == 0 {
+		return nil
+	}
+	neighbours := make(map[int][]int)
+	res := make([]Record, n)
+	for i := range res {
+		a := r.Intn(len(recs))
+		nn, ok := neighbours[a]
+		if !ok {
+			nn = nearest(recs, a, k)
+			neighbours[a] = nn
+		}
+		res[i] = synthetic(recs[a])
+		if len(nn) == 0 {
+			continue
+		}
+		b := recs[nn[r.Intn(len(nn))]]
+		u := r.Float64()
+		for j := range res[i].Data {
+			res[i].Data[j] += u * (b.Data[j] - res[i].Data[j])
+		}
+	}
+	return res
+}
+
+// nearest returns the indexes of the k samples nearest to the i-th one in feature space.
+func nearest(recs []Record, i, k int) []int {
+	type neighbour struct {
+		index int
+		dist  float64
+	}
+	var nn []neighbour
+	for j, rec := range recs {
+		if j == i {
+			continue
+		}
+		var dist float64
+		for f, v := range rec.Data {
+			d := v - recs[i].Data[f]
+			dist += d * d
+		}
+		nn = append(nn, neighbour{j, dist})
+	}
+	sort.Slice(nn, func(a, b int) bool { return nn[a].dist < nn[b].dist })
+	if len(nn) > k {
+		nn = nn[:k]
+	}
+	res := make([]int, len(nn))
+	for j, n := range nn {
+		res[j] = n.index
+	}
+	return res
+}
+
+// synthetic returns a copy of the sample marked synthetic.
+func synthetic(rec Record) Record {
+	rec.Data = append([]float64{}, rec.Data...)
+	rec.Synthetic = true
+	return rec
+}
+
+// ClazzCount is the number of samples of a class, of synthetic ones among them, and their total weight.
+type ClazzCount struct {
+	ID        int     `json:"id"`
+	Samples   int     `json:"samples"`
+	Synthetic int     `json:"synthetic"`
+	Weight    float64 `json:"weight"`
+}
+
+// Counts returns the samples of every class by class name, with zero counts for classes without samples.
+func Counts(recs []Record, clazzId map[string]int) map[string]ClazzCount {
+	res := make(map[string]ClazzCount)
+	for clazz, id := range clazzId {
+		res[clazz] = ClazzCount{ID: id}
+	}
+	for _, rec := range recs {
+		c := res[rec.Clazz]
+		c.Samples++
+		if rec.Synthetic {
+			c.Synthetic++
+		}
+		c.Weight += rec.Weight
+		res[rec.Clazz] = c
+	}
+	return res
+}
@@ -0,0 +1,53 @@
+package data
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func balanceRecords() []Record {
+	var res []Record
+	for i := 0; i < 60; i++ {
+		clazz := "water"
+		if i%6 == 0 {
+			clazz = "pine"
+		}
+		res = append(res, testRecord(clazz, "", i, i, float64(i), float64(i%6)))
+	}
+	return res
+}
+
+func TestBalance(t *testing.T) {
+	recs := balanceRecords()
+	for strategy, expected := range map[string][2]int{
+		BalanceNone:        {50, 10},
+		BalanceUndersample: {10, 10},
+		BalanceOversample:  {50, 50},
+		BalanceSMOTE:       {50, 50},
+		BalanceWeights:     {50, 10},
+	} {
+		opts := DefaultBalanceOptions()
+		opts.Strategy = strategy
+		counts := Counts(Balance(recs, testClazzId, opts, rand.New(rand.NewSource(42))), testClazzId)
+		water, pine := counts["water"], counts["pine"]
+		if water.Samples != expected[0] || pine.Samples != expected[1] {
+			t.Errorf("%s: expected %v samples, found %d and %d", strategy, expected, water.Samples, pine.Samples)
+		}
+		if pine.Synthetic != pine.Samples-10 || water.Synthetic != 0 {
+			t.Errorf("%s: unexpected synthetic samples %d and %d", strategy, water.Synthetic, pine.Synthetic)
+		}
+		if strategy == BalanceWeights && (math.Abs(water.Weight-30) > 1e-9 || math.Abs(pine.Weight-30) > 1e-9) {
+			t.Errorf("%s: expected equal total weights of 30, found %v and %v", strategy, water.Weight, pine.Weight)
+		}
+	}
+
+	opts := DefaultBalanceOptions()
+	opts.Strategy = BalanceSMOTE
+	for _, rec := range Balance(recs, testClazzId, opts, rand.New(rand.NewSource(42))) {
+		// pine samples are at multiples of 6 on the diagonal, interpolations stay between them
+		if rec.Clazz == "pine" && (rec.Data[1] != 0 || rec.Data[0] < 0 || rec.Data[0] > 54) {
+			t.Errorf("unexpected synthetic sample %v", rec.Data)
+		}
+	}
+}
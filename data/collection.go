@@ -2,23 +2,30 @@ package data
 
 import (
 	"bufio"
-	"encoding/csv"
 	"fmt"
+	"math"
 	"math/rand"
-	"os"
 	"path"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
-	"github.com/nordicsense/gdal"
 	"github.com/nordicsense/landsat/dataset"
 	"github.com/nordicsense/landsat/io"
+	"github.com/nordicsense/landsat/product"
 )
 
 var coordRe = regexp.MustCompile(`^\s+(\d{1,4})\s+(\d{1,4})(?:\s+\d{1,3})+$`)
 
-type coordinates [][2]int
+// coordinate is a 1-based pixel position with the field data file it was read from.
+type coordinate struct {
+	xy     [2]int
+	source string
+}
+
+type coordinates []coordinate
 type coordinateMap map[string]coordinates
 
 // class -> image -> coodinates
@@ -34,7 +41,6 @@ func CollectCoordinates(pathIn string) (map[string]coordinateMap, error) {
 	res := make(map[string]coordinateMap)
 	for _, fName := range fNames {
 		var (
-			f   *os.File
 			cm  coordinateMap
 			ccs coordinates
 			ok  bool
@@ -48,21 +54,8 @@ func CollectCoordinates(pathIn string) (map[string]coordinateMap, error) {
 			return parts[len(parts)-3]
 		}()
 		ccs = cm[image]
-		if f, err = os.Open(fName); err == nil {
-			scanner := bufio.NewScanner(f)
-			for scanner.Scan() {
-				line := scanner.Text()
-				if coord := coordRe.FindAllStringSubmatch(line, 1); len(coord) == 1 && len(coord[0]) == 3 {
-					// regex should guarantee conformance to int
-					x, _ := strconv.Atoi(coord[0][1])
-					y, _ := strconv.Atoi(coord[0][2])
-					ccs = append(ccs, [2]int{x, y})
-				}
-			}
-			err = scanner.Err()
-			_ = f.Close()
-		}
-		if err != nil {
+		source, _ := filepath.Rel(pathIn, fName)
+		if ccs, err = readCoordinates(fName, source, ccs); err != nil {
 			break
 		}
 		// make conditional on non-empty content
@@ -72,11 +65,41 @@ func CollectCoordinates(pathIn string) (map[string]coordinateMap, error) {
 	return res, err
 }
 
+// readCoordinates appends the coordinates read from a local or remote field data file.
+func readCoordinates(fName, source string, ccs coordinates) (coordinates, error) {
+	f, err := io.Open(fName)
+	if err != nil {
+		return ccs, err
+	}
+	defer func() { _ = f.Close() }()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if coord := coordRe.FindAllStringSubmatch(line, 1); len(coord) == 1 && len(coord[0]) == 3 {
+			// regex should guarantee conformance to int
+			x, _ := strconv.Atoi(coord[0][1])
+			y, _ := strconv.Atoi(coord[0][2])
+			ccs = append(ccs, coordinate{[2]int{x, y}, source})
+		}
+	}
+	return ccs, scanner.Err()
+}
+
+// Record is a training sample with its provenance: the field data class it was labelled with and the file,
+// relative to the field data directory, it was read from, the image and 1-based pixel coordinates it was read
+// at, the location of the pixel centre and the acquisition date. Samples are weighed 1 unless balanced by
+// weights; synthetic ones are added by balancing and carry the provenance of the sample they derive from.
 type Record struct {
-	Image  string
-	Clazz  string
-	Coords [2]int
-	Data   []float64
+	Image     string
+	Clazz     string
+	Label     string
+	Source    string
+	Coords    [2]int
+	LatLon    dataset.LatLon
+	Acquired  time.Time
+	Data      []float64
+	Weight    float64
+	Synthetic bool
 }
 
 type Converter func(string, string, []float64) (string, []float64, bool)
@@ -85,7 +108,10 @@ var PathThrough Converter = func(image string, clazz string, data []float64) (st
 	return clazz, data, true
 }
 
-func TrainingData(pathIn, pattern string, coords map[string]coordinateMap, images map[string]bool, convert Converter) ([]Record, error) {
+// TrainingData reads the band values in the sampling windows around the field data coordinates from the images
+// of the scenes listed, all without list, and selected, or the temporal features over the season of selected
+// images of each. Windows failing the homogeneity test and pixels outside the image are left out.
+func TrainingData(pathIn, pattern string, coords map[string]coordinateMap, images map[string]bool, selects func(fileName string) bool, sampling SamplingOptions, convert Converter) ([]Record, error) {
 	var (
 		err         error
 		imageFNames []string
@@ -98,34 +124,36 @@ func TrainingData(pathIn, pattern string, coords map[string]coordinateMap, image
 
 	for _, cm := range coords {
 		for im := range cm {
-			if images[im] {
+			if images == nil || images[im] {
 				imageNames[im] = true
 			}
 		}
 	}
 
+	var selected []string
+	for _, n := range imageFNames {
+		if selects(n) {
+			selected = append(selected, n)
+		}
+	}
+
 	for im := range imageNames {
+		imID, err := product.ParseID(im)
+		if err != nil {
+			return nil, err
+		}
+		// images of the scene at another processing level, e.g. L2SP rather than L1TP, are used when needed
 		fName := ""
 		for _, n := range imageFNames {
-			if strings.Contains(n, im) {
+			if id, err := product.ParseID(n); err == nil && id.SameScene(imID) && (fName == "" || id.Level == imID.Level) {
 				fName = n
-				break
 			}
 		}
-
 		if fName == "" {
-			// Try L2SP instead of L1TP
-			imx := strings.Replace(im, "L1TP", "L2SP", 1)
-			for _, n := range imageFNames {
-				if strings.Contains(n, imx) {
-					fName = n
-					break
-				}
-			}
-
-			if fName == "" {
-				return nil, fmt.Errorf("could not find image for patterns %s and %s", im, imx)
-			}
+			return nil, fmt.Errorf("could not find image of scene %s", im)
+		}
+		if !selects(fName) {
+			continue
 		}
 
 		err = func() error { // for scoping reader closure
@@ -135,33 +163,108 @@ func TrainingData(pathIn, pattern string, coords map[string]coordinateMap, image
 			}
 			defer r.Close()
 
-			buf := make([]float64, 1)
+			offsets := sampling.offsets(math.Abs(r.ImageParams().Transform()[1]))
+			var pixels [][2]int
+			for _, cm := range coords {
+				for _, cc := range cm[im] {
+					for _, off := range offsets {
+						pixels = append(pixels, [2]int{cc.xy[0] - 1 + off[0], cc.xy[1] - 1 + off[1]})
+					}
+				}
+			}
+			// ugly performance workaround to get access to the raw reader
+			values, err := readPixels(r.Reader(1).BreakGlass(), 7, pixels)
+			if err != nil {
+				return err
+			}
+			var season []string
+			var seasonValues []map[[2]int][]float64
+			var days []float64
+			if sampling.Temporal {
+				season = Season(selected, imID)
+				for _, n := range season {
+					vv := values
+					if n != fName {
+						if vv, err = readCoregistered(n, r.ImageParams(), pixels); err != nil {
+							return err
+						}
+					}
+					id, _ := product.ParseID(n)
+					seasonValues = append(seasonValues, vv)
+					days = append(days, float64(id.Acquired.YearDay()))
+				}
+			}
+			// sample converts the band values of the pixel into features, temporal ones over the season
+			sample := func(clazz string, px [2]int) (string, []float64, bool) {
+				if !sampling.Temporal {
+					return convert(im, clazz, append([]float64{}, values[px]...))
+				}
+				newclazz := ""
+				series := make([][]float64, len(season))
+				for i, n := range season {
+					series[i] = []float64{math.NaN()}
+					if xx, ok := seasonValues[i][px]; ok {
+						if c, xxt, ok := convert(n, clazz, append([]float64{}, xx...)); ok && len(xxt) == NVars {
+							newclazz, series[i] = c, xxt
+						}
+					}
+				}
+				return newclazz, Temporal(series, days), newclazz != ""
+			}
 
 			for clazz, cm := range coords {
 				ccs, ok := cm[im]
 				if !ok {
 					continue
 				}
-				// ugly performance workaround to get access to the raw reader
-				ds := r.Reader(1).BreakGlass()
+				// windows of neighbouring coordinates overlap, their pixels are sampled once
+				seen := make(map[[2]int]bool)
+				var (
+					recs   []Record
+					pixels [][2]int
+				)
 				for _, cc := range ccs {
-					xx := make([]float64, 7)
-					for band := 0; band < 7; band++ {
-						if err = ds.RasterBand(band+1).IO(gdal.Read, cc[0]-1, cc[1]-1, 1, 1, buf, 1, 1, 0, 0); err != nil {
-							return err
+					var window [][2]int
+					var xxs [][]float64
+					for _, off := range offsets {
+						px := [2]int{cc.xy[0] - 1 + off[0], cc.xy[1] - 1 + off[1]}
+						if xx, ok := values[px]; ok {
+							window = append(window, px)
+							xxs = append(xxs, xx)
 						}
-						xx[band] = buf[0]
 					}
-					newclazz, newdata, ok := convert(im, clazz, xx)
-					if ok {
-						res = append(res, Record{
-							Image:  im,
-							Clazz:  newclazz,
-							Coords: cc,
-							Data:   newdata,
-						})
+					if !sampling.homogeneous(xxs) {
+						continue
 					}
+					for _, px := range window {
+						if len(offsets) > 1 && seen[px] {
+							continue
+						}
+						seen[px] = true
+						newclazz, newdata, ok := sample(clazz, px)
+						if ok {
+							recs = append(recs, Record{
+								Image:    im,
+								Clazz:    newclazz,
+								Label:    clazz,
+								Source:   cc.source,
+								Coords:   [2]int{px[0] + 1, px[1] + 1},
+								Acquired: imID.Acquired,
+								Data:     newdata,
+								Weight:   1,
+							})
+							pixels = append(pixels, px)
+						}
+					}
+				}
+				lls, err := r.ImageParams().PixelsDegrees(pixels)
+				if err != nil {
+					return err
+				}
+				for i := range recs {
+					recs[i].LatLon = lls[i]
 				}
+				res = append(res, recs...)
 			}
 			return nil
 		}()
@@ -178,7 +281,7 @@ func Subsample(recs []Record, clazzId map[string]int, clazzSize, testSize int, r
 		byClazz[rec.Clazz] = append(byClazz[rec.Clazz], rec)
 	}
 
-	for clazz := range clazzId {
+	for _, clazz := range sortedClazzes(clazzId) {
 		xx := byClazz[clazz]
 		nn := len(xx)
 		idx := r.Perm(nn)
@@ -205,29 +308,3 @@ func Subsample(recs []Record, clazzId map[string]int, clazzSize, testSize int, r
 	}
 	return train, test
 }
-
-func DumpCSV(name string, recs []Record, clazzId map[string]int) error {
-	fo, err := os.Create(name)
-	if err != nil {
-		return err
-	}
-	defer func() { _ = fo.Close() }()
-	w := csv.NewWriter(fo)
-	defer w.Flush()
-
-	l := append([]string{"clazz", "clazzid"}, Clazzes...)
-	if err = w.Write(l); err != nil {
-		return err
-	}
-	for _, r := range recs {
-		l[0] = r.Clazz
-		l[1] = strconv.Itoa(clazzId[r.Clazz])
-		for i, v := range r.Data {
-			l[i+2] = strconv.FormatFloat(v, 'f', 6, 64)
-		}
-		if err = w.Write(l); err != nil {
-			return err
-		}
-	}
-	return nil
-}
@@ -0,0 +1,15 @@
+package data
+
+import "time"
+
+var testClazzId = map[string]int{"water": 1, "pine": 7}
+
+// testRecord returns a sample of the class at the pixel coordinates of the image, acquired on the date the image
+// name ends with, of field data named after the class, with the feature values and weight 1.
+func testRecord(clazz, image string, x, y int, data ...float64) Record {
+	rec := Record{Image: image, Clazz: clazz, Label: clazz, Source: clazz + ".asc", Coords: [2]int{x, y}, Data: data, Weight: 1}
+	if len(image) >= len("20060102") {
+		rec.Acquired, _ = time.Parse("20060102", image[len(image)-len("20060102"):])
+	}
+	return rec
+}
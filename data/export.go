@@ -0,0 +1,148 @@
+package data
+
+import (
+	"encoding/csv"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nordicsense/landsat/io"
+)
+
+// Export formats by file extension.
+const (
+	FormatCSV     = "csv"
+	FormatParquet = "parquet"
+	FormatNPZ     = "npz"
+)
+
+const (
+	dateFormat    = "2006-01-02"
+	secondsPerDay = 24 * 60 * 60
+)
+
+type columnKind int
+
+const (
+	stringColumn columnKind = iota
+	intColumn
+	floatColumn
+	dateColumn
+)
+
+// column holds the values of an exported column, strings, integers, floats or dates as days since the
+// epoch.
+type column struct {
+	name   string
+	kind   columnKind
+	strs   []string
+	ints   []int64
+	floats []float64
+}
+
+// columns lays out the records column-wise: the class and its id, the feature values, temporal ones if the
+// records hold them, and the provenance of the samples, being the field data label and file, image, acquisition
+// date, 1-based pixel coordinates and location, followed by the sample weight and 1 for synthetic samples, 0
+// otherwise.
+func columns(recs []Record, clazzId map[string]int) []column {
+	res := []column{{name: "clazz", kind: stringColumn}, {name: "clazzid", kind: intColumn}}
+	names := Clazzes
+	if len(recs) > 0 {
+		names = FeatureNames(len(recs[0].Data))
+	}
+	for _, name := range names {
+		res = append(res, column{name: name, kind: floatColumn})
+	}
+	res = append(res,
+		column{name: "label", kind: stringColumn},
+		column{name: "source", kind: stringColumn},
+		column{name: "image", kind: stringColumn},
+		column{name: "acquired", kind: dateColumn},
+		column{name: "x", kind: intColumn},
+		column{name: "y", kind: intColumn},
+		column{name: "lat", kind: floatColumn},
+		column{name: "lon", kind: floatColumn},
+		column{name: "weight", kind: floatColumn},
+		column{name: "synthetic", kind: intColumn})
+
+	features := res[2 : 2+len(names)]
+	prov := res[2+len(names):]
+	for _, r := range recs {
+		res[0].strs = append(res[0].strs, r.Clazz)
+		res[1].ints = append(res[1].ints, int64(clazzId[r.Clazz]))
+		for i := range features {
+			features[i].floats = append(features[i].floats, r.Data[i])
+		}
+		prov[0].strs = append(prov[0].strs, r.Label)
+		prov[1].strs = append(prov[1].strs, r.Source)
+		prov[2].strs = append(prov[2].strs, r.Image)
+		prov[3].ints = append(prov[3].ints, r.Acquired.Unix()/secondsPerDay)
+		prov[4].ints = append(prov[4].ints, int64(r.Coords[0]))
+		prov[5].ints = append(prov[5].ints, int64(r.Coords[1]))
+		prov[6].floats = append(prov[6].floats, r.LatLon[0])
+		prov[7].floats = append(prov[7].floats, r.LatLon[1])
+		prov[8].floats = append(prov[8].floats, r.Weight)
+		synthetic := int64(0)
+		if r.Synthetic {
+			synthetic = 1
+		}
+		prov[9].ints = append(prov[9].ints, synthetic)
+	}
+	return res
+}
+
+// Dump writes the records in the format given by the file extension: CSV, Parquet or NumPy .npz.
+func Dump(name string, recs []Record, clazzId map[string]int) error {
+	switch strings.ToLower(strings.TrimPrefix(path.Ext(name), ".")) {
+	case FormatCSV:
+		return DumpCSV(name, recs, clazzId)
+	case FormatParquet:
+		return DumpParquet(name, recs, clazzId)
+	case FormatNPZ:
+		return DumpNPZ(name, recs, clazzId)
+	}
+	return fmt.Errorf("unsupported training data format of %s, expected .csv, .parquet or .npz", name)
+}
+
+// DumpCSV writes the records as CSV with a header row, feature values with 6 decimals.
+func DumpCSV(name string, recs []Record, clazzId map[string]int) error {
+	fo, err := io.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fo.Close() }()
+	w := csv.NewWriter(fo)
+
+	cols := columns(recs, clazzId)
+	l := make([]string, len(cols))
+	for i, c := range cols {
+		l[i] = c.name
+	}
+	if err = w.Write(l); err != nil {
+		return err
+	}
+	for j := range recs {
+		for i, c := range cols {
+			switch c.kind {
+			case stringColumn:
+				l[i] = c.strs[j]
+			case intColumn:
+				l[i] = strconv.FormatInt(c.ints[j], 10)
+			case floatColumn:
+				l[i] = strconv.FormatFloat(c.floats[j], 'f', 6, 64)
+			case dateColumn:
+				l[i] = time.Unix(c.ints[j]*secondsPerDay, 0).UTC().Format(dateFormat)
+			}
+		}
+		if err = w.Write(l); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return err
+	}
+	return fo.Commit()
+}
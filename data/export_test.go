@@ -0,0 +1,156 @@
+package data
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"io"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func testRecords() []Record {
+	data := make([]float64, NVars)
+	for i := range data {
+		data[i] = float64(i) / 10
+	}
+	water := testRecord("water", "LT05_L1TP_188012_19860728", 12, 34, data...)
+	water.Label, water.Source, water.LatLon = "water_with_no_sediments", "a/water_with_no_sediments.asc", [2]float64{68.5, 33.25}
+	pine := testRecord("pine", "LT05_L1TP_188012_19860728", 56, 78, data...)
+	pine.Label, pine.Source, pine.LatLon = "road_åsen", "b/road_åsen.asc", [2]float64{68.75, 33.5}
+	return []Record{water, pine}
+}
+
+func TestDumpCSV(t *testing.T) {
+	fileName := path.Join(t.TempDir(), "training.csv")
+	if err := Dump(fileName, testRecords(), testClazzId); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || len(rows[0]) != 2+NVars+10 {
+		t.Fatalf("unexpected rows %v", rows)
+	}
+	expected := []string{"pine", "7", "0.000000", "0.100000"}
+	if !reflect.DeepEqual(rows[2][:4], expected) {
+		t.Errorf("expected %v, found %v", expected, rows[2][:4])
+	}
+	expected = []string{"road_åsen", "b/road_åsen.asc", "LT05_L1TP_188012_19860728", "1986-07-28", "56", "78", "68.750000", "33.500000", "1.000000", "0"}
+	if !reflect.DeepEqual(rows[2][2+NVars:], expected) {
+		t.Errorf("expected %v, found %v", expected, rows[2][2+NVars:])
+	}
+}
+
+func TestDumpNPZ(t *testing.T) {
+	fileName := path.Join(t.TempDir(), "training.npz")
+	if err := Dump(fileName, testRecords(), testClazzId); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.OpenReader(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	arrays := make(map[string][]byte)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		arrays[f.Name], _ = io.ReadAll(r)
+		_ = r.Close()
+	}
+	if len(arrays) != 2+NVars+10 {
+		t.Fatalf("unexpected arrays %d", len(arrays))
+	}
+	for name, descr := range map[string]string{"label.npy": "<U23", "clazzid.npy": "<i8", "acquired.npy": "<M8[D]", "lat.npy": "<f8"} {
+		a := arrays[name]
+		if !bytes.HasPrefix(a, []byte(npyMagic)) {
+			t.Fatalf("%s: missing magic", name)
+		}
+		headerLen := int(binary.LittleEndian.Uint16(a[8:]))
+		header := string(a[10 : 10+headerLen])
+		if (10+headerLen)%64 != 0 || !strings.HasSuffix(header, "\n") ||
+			!strings.HasPrefix(header, "{'descr': '"+descr+"', 'fortran_order': False, 'shape': (2,), }") {
+			t.Errorf("%s: unexpected header %q", name, header)
+		}
+	}
+	a := arrays["acquired.npy"]
+	if days := int64(binary.LittleEndian.Uint64(a[len(a)-8:])); days != 6052 {
+		t.Errorf("expected 6052 days since the epoch, found %d", days)
+	}
+	a = arrays["label.npy"]
+	if r := rune(binary.LittleEndian.Uint32(a[len(a)-23*4+5*4:])); r != 'å' {
+		t.Errorf("expected å, found %c", r)
+	}
+}
+
+func TestDumpParquet(t *testing.T) {
+	fileName := path.Join(t.TempDir(), "training.parquet")
+	if err := Dump(fileName, testRecords(), testClazzId); err != nil {
+		t.Fatal(err)
+	}
+	fr, err := local.NewLocalFileReader(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = fr.Close() }()
+	pr, err := reader.NewParquetColumnReader(fr, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	if n := pr.GetNumRows(); n != 2 {
+		t.Fatalf("expected 2 rows, found %d", n)
+	}
+	schema := pr.Footer.Schema
+	if len(schema) != 1+2+NVars+10 {
+		t.Fatalf("unexpected schema %v", schema)
+	}
+	var found []string
+	for i, element := range schema[1:] {
+		values, _, _, err := pr.ReadColumnByIndex(int64(i), 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := pr.SchemaHandler.GetExName(i + 1)
+		found = append(found, name)
+		switch name {
+		case "label":
+			if !reflect.DeepEqual(values, []interface{}{"water_with_no_sediments", "road_åsen"}) {
+				t.Errorf("unexpected labels %v", values)
+			}
+		case "y":
+			if !reflect.DeepEqual(values, []interface{}{int64(34), int64(78)}) {
+				t.Errorf("unexpected y %v", values)
+			}
+		case "acquired":
+			if values[0] != int32(6052) || element.GetConvertedType() != parquet.ConvertedType_DATE {
+				t.Errorf("expected 6052 days since the epoch as date, found %v", values[0])
+			}
+		case "lat":
+			if !reflect.DeepEqual(values, []interface{}{68.5, 68.75}) {
+				t.Errorf("unexpected latitudes %v", values)
+			}
+		}
+	}
+	if expected := []string{"clazz", "clazzid", Clazzes[0], "label", "acquired", "lat", "synthetic"}; !reflect.DeepEqual(
+		[]string{found[0], found[1], found[2], found[2+NVars], found[5+NVars], found[8+NVars], found[11+NVars]}, expected) {
+		t.Errorf("unexpected columns %v", found)
+	}
+}
@@ -0,0 +1,98 @@
+package data
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/nordicsense/landsat/io"
+)
+
+// npyMagic starts NumPy .npy files of format version 1.0.
+const npyMagic = "\x93NUMPY\x01\x00"
+
+// DumpNPZ writes the records as a NumPy .npz archive holding one array per column, as loaded by numpy.load:
+// strings as unicode, integers as int64, floats as float64 and acquisition dates as datetime64[D].
+func DumpNPZ(name string, recs []Record, clazzId map[string]int) error {
+	fo, err := io.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fo.Close() }()
+	zw := zip.NewWriter(fo)
+	for _, c := range columns(recs, clazzId) {
+		w, err := zw.Create(c.name + ".npy")
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(npy(c, len(recs))); err != nil {
+			return err
+		}
+	}
+	if err = zw.Close(); err != nil {
+		return err
+	}
+	return fo.Commit()
+}
+
+// npy encodes a column as one-dimensional .npy array.
+func npy(c column, n int) []byte {
+	var (
+		descr string
+		data  bytes.Buffer
+		b     = make([]byte, 8)
+	)
+	switch c.kind {
+	case stringColumn:
+		// fixed width UTF-32 of the longest value
+		width := 1
+		for _, v := range c.strs {
+			if l := utf8.RuneCountInString(v); l > width {
+				width = l
+			}
+		}
+		descr = fmt.Sprintf("<U%d", width)
+		for _, v := range c.strs {
+			runes := []rune(v)
+			for i := 0; i < width; i++ {
+				var r rune
+				if i < len(runes) {
+					r = runes[i]
+				}
+				binary.LittleEndian.PutUint32(b, uint32(r))
+				data.Write(b[:4])
+			}
+		}
+	case intColumn, dateColumn:
+		descr = "<i8"
+		if c.kind == dateColumn {
+			descr = "<M8[D]"
+		}
+		for _, v := range c.ints {
+			binary.LittleEndian.PutUint64(b, uint64(v))
+			data.Write(b)
+		}
+	case floatColumn:
+		descr = "<f8"
+		for _, v := range c.floats {
+			binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+			data.Write(b)
+		}
+	}
+	header := fmt.Sprintf("{'descr': '%s', 'fortran_order': False, 'shape': (%d,), }", descr, n)
+	// the header is padded with spaces and terminated by a newline for the data to be aligned at 64 bytes
+	total := len(npyMagic) + 2 + len(header) + 1
+	header += strings.Repeat(" ", (64-total%64)%64) + "\n"
+
+	var res bytes.Buffer
+	res.WriteString(npyMagic)
+	binary.LittleEndian.PutUint16(b, uint16(len(header)))
+	res.Write(b[:2])
+	res.WriteString(header)
+	res.Write(data.Bytes())
+	return res.Bytes()
+}
@@ -0,0 +1,79 @@
+package data
+
+import (
+	"bufio"
+	"fmt"
+
+	"github.com/nordicsense/landsat/io"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// parquetRowGroupSize bounds the memory taken by rows buffered before they are written out as a row group.
+const parquetRowGroupSize = 64 << 20
+
+// DumpParquet writes the records as a Snappy compressed Parquet file of required columns, acquisition dates as
+// DATE. Rows are streamed to the file in row groups.
+func DumpParquet(name string, recs []Record, clazzId map[string]int) error {
+	fo, err := io.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fo.Close() }()
+	bw := bufio.NewWriter(fo)
+
+	cols := columns(recs, clazzId)
+	schema := make([]string, len(cols))
+	for i, c := range cols {
+		schema[i] = parquetSchema(c)
+	}
+	pw, err := writer.NewCSVWriterFromWriter(schema, bw, 1)
+	if err != nil {
+		return err
+	}
+	pw.RowGroupSize = parquetRowGroupSize
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+
+	for j := range recs {
+		row := make([]interface{}, len(cols))
+		for i, c := range cols {
+			switch c.kind {
+			case stringColumn:
+				row[i] = c.strs[j]
+			case intColumn:
+				row[i] = c.ints[j]
+			case floatColumn:
+				row[i] = c.floats[j]
+			case dateColumn:
+				row[i] = int32(c.ints[j])
+			}
+		}
+		if err = pw.Write(row); err != nil {
+			return err
+		}
+	}
+	if err = pw.WriteStop(); err != nil {
+		return err
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	return fo.Commit()
+}
+
+// parquetSchema returns the schema metadata of a column: strings as dictionary encoded UTF8, integers as INT64,
+// dates as INT32 days since the epoch and floats as DOUBLE.
+func parquetSchema(c column) string {
+	var typ string
+	switch c.kind {
+	case stringColumn:
+		typ = "type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY"
+	case intColumn:
+		typ = "type=INT64"
+	case dateColumn:
+		typ = "type=INT32, convertedtype=DATE"
+	case floatColumn:
+		typ = "type=DOUBLE"
+	}
+	return fmt.Sprintf("name=%s, %s, repetitiontype=REQUIRED", c.name, typ)
+}
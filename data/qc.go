@@ -0,0 +1,275 @@
+package data
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/nordicsense/landsat/io"
+)
+
+// Reasons samples are flagged for review.
+const (
+	// FlagOutlier marks samples spectrally far from the other samples of their class.
+	FlagOutlier = "outlier"
+	// FlagBoundary marks samples spectrally closer to another class than to their own.
+	FlagBoundary = "boundary"
+	// FlagCloudLike marks samples spectrally closer to the cloud class than to their own. No cloud mask is read,
+	// so clouds unlike the cloud samples go unflagged and bright surfaces like them are flagged.
+	FlagCloudLike = "cloud-like"
+)
+
+// QCOptions configure the quality control of training samples.
+type QCOptions struct {
+	// Quantile of the chi-square distribution of squared Mahalanobis distances beyond which samples are
+	// outliers of their class.
+	Quantile float64
+	// Margin flags samples whose distance to another class is below the margin times that to their own.
+	Margin float64
+	// CloudClazz is the class of cloud samples, boundary samples towards it are flagged as cloud-like.
+	CloudClazz string
+	// Exclude drops the flagged samples from training and test data.
+	Exclude bool
+}
+
+// DefaultQCOptions returns outliers beyond the 0.999 quantile and samples closer to another class than to
+// their own, without excluding them.
+func DefaultQCOptions() QCOptions {
+	return QCOptions{Quantile: 0.999, Margin: 1}
+}
+
+// Validate checks the numeric options.
+func (o QCOptions) Validate() error {
+	if o.Quantile <= 0 || o.Quantile >= 1 || o.Margin < 0 {
+		return fmt.Errorf("quantile must be between 0 and 1 and margin must not be negative")
+	}
+	return nil
+}
+
+// Flag is a sample flagged for review: the index of the sample, the reasons, its Mahalanobis distance to its
+// class and the nearest other class with the distance to it.
+type Flag struct {
+	Index           int
+	Record          Record
+	Reasons         []string
+	Distance        float64
+	Nearest         string
+	NearestDistance float64
+}
+
+// clazzStats are the mean and the Cholesky factor of the covariance of the features of a class.
+type clazzStats struct {
+	mean []float64
+	chol [][]float64
+}
+
+// Inspect flags outliers of their class and samples near the boundary to another class by the Mahalanobis
+// distance of their features to the classes. Class statistics are estimated once more without the outliers
+// of the first estimate; classes of fewer samples than features plus one are not inspected.
+func Inspect(recs []Record, opts QCOptions) []Flag {
+	if len(recs) == 0 {
+		return nil
+	}
+	dims := len(recs[0].Data)
+	threshold := chiSquareQuantile(opts.Quantile, dims)
+
+	byClazz := make(map[string][]int)
+	for i, rec := range recs {
+		byClazz[rec.Clazz] = append(byClazz[rec.Clazz], i)
+	}
+	stats := make(map[string]*clazzStats)
+	for clazz, idx := range byClazz {
+		s := newClazzStats(recs, idx)
+		if s == nil {
+			continue
+		}
+		var inliers []int
+		for _, i := range idx {
+			if s.distance2(recs[i].Data) <= threshold {
+				inliers = append(inliers, i)
+			}
+		}
+		if robust := newClazzStats(recs, inliers); robust != nil {
+			s = robust
+		}
+		stats[clazz] = s
+	}
+	var clazzes []string
+	for clazz := range stats {
+		clazzes = append(clazzes, clazz)
+	}
+	sort.Strings(clazzes)
+
+	var res []Flag
+	for i, rec := range recs {
+		s, ok := stats[rec.Clazz]
+		if !ok {
+			continue
+		}
+		d2 := s.distance2(rec.Data)
+		flag := Flag{Index: i, Record: rec, Distance: math.Sqrt(d2), NearestDistance: math.Inf(1)}
+		if d2 > threshold {
+			flag.Reasons = append(flag.Reasons, FlagOutlier)
+		}
+		for _, clazz := range clazzes {
+			if clazz == rec.Clazz {
+				continue
+			}
+			if d := math.Sqrt(stats[clazz].distance2(rec.Data)); d < flag.NearestDistance {
+				flag.Nearest, flag.NearestDistance = clazz, d
+			}
+		}
+		if flag.Nearest != "" && flag.NearestDistance < opts.Margin*flag.Distance {
+			if flag.Nearest == opts.CloudClazz {
+				flag.Reasons = append(flag.Reasons, FlagCloudLike)
+			} else {
+				flag.Reasons = append(flag.Reasons, FlagBoundary)
+			}
+		}
+		if len(flag.Reasons) > 0 {
+			res = append(res, flag)
+		}
+	}
+	return res
+}
+
+// Exclude returns the samples not flagged.
+func Exclude(recs []Record, flags []Flag) []Record {
+	flagged := make(map[int]bool)
+	for _, f := range flags {
+		flagged[f.Index] = true
+	}
+	var res []Record
+	for i, rec := range recs {
+		if !flagged[i] {
+			res = append(res, rec)
+		}
+	}
+	return res
+}
+
+// DumpFlags writes the flagged samples as CSV for review, with their image, 1-based pixel coordinates and
+// location.
+func DumpFlags(name string, flags []Flag) error {
+	fo, err := io.Create(name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = fo.Close() }()
+	w := csv.NewWriter(fo)
+
+	header := []string{"image", "x", "y", "lat", "lon", "clazz", "label", "source", "reason", "distance", "nearest", "nearest_distance"}
+	if err = w.Write(header); err != nil {
+		return err
+	}
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', 6, 64) }
+	for _, f := range flags {
+		r := f.Record
+		nearest := ""
+		if f.Nearest != "" {
+			nearest = format(f.NearestDistance)
+		}
+		l := []string{r.Image, strconv.Itoa(r.Coords[0]), strconv.Itoa(r.Coords[1]), format(r.LatLon[0]), format(r.LatLon[1]),
+			r.Clazz, r.Label, r.Source, strings.Join(f.Reasons, ";"), format(f.Distance), f.Nearest, nearest}
+		if err = w.Write(l); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return err
+	}
+	return fo.Commit()
+}
+
+// newClazzStats estimates the statistics of the samples at the indexes, nil for too few samples. The
+// covariance is regularised as derived indices make it near singular.
+func newClazzStats(recs []Record, idx []int) *clazzStats {
+	if len(idx) == 0 || len(idx) <= len(recs[idx[0]].Data) {
+		return nil
+	}
+	dims := len(recs[idx[0]].Data)
+	mean := make([]float64, dims)
+	for _, i := range idx {
+		for j, v := range recs[i].Data {
+			mean[j] += v / float64(len(idx))
+		}
+	}
+	cov := make([][]float64, dims)
+	for j := range cov {
+		cov[j] = make([]float64, dims)
+	}
+	for _, i := range idx {
+		x := recs[i].Data
+		for j := range cov {
+			for k := 0; k <= j; k++ {
+				cov[j][k] += (x[j] - mean[j]) * (x[k] - mean[k]) / float64(len(idx)-1)
+			}
+		}
+	}
+	var trace float64
+	for j := range cov {
+		trace += cov[j][j]
+	}
+	ridge := 1e-6*trace/float64(dims) + 1e-12
+	for j := range cov {
+		cov[j][j] += ridge
+	}
+	chol := cholesky(cov)
+	if chol == nil {
+		return nil
+	}
+	return &clazzStats{mean: mean, chol: chol}
+}
+
+// distance2 returns the squared Mahalanobis distance of the features to the class.
+func (s *clazzStats) distance2(x []float64) float64 {
+	// solves L y = x - mean by forward substitution, the squared distance being |y|^2
+	y := make([]float64, len(x))
+	var res float64
+	for j := range y {
+		v := x[j] - s.mean[j]
+		for k := 0; k < j; k++ {
+			v -= s.chol[j][k] * y[k]
+		}
+		y[j] = v / s.chol[j][j]
+		res += y[j] * y[j]
+	}
+	return res
+}
+
+// cholesky returns the lower triangular factor of the symmetric matrix given by its lower triangle, nil if the
+// matrix is not positive definite.
+func cholesky(a [][]float64) [][]float64 {
+	res := make([][]float64, len(a))
+	for j := range res {
+		res[j] = make([]float64, len(a))
+		for k := 0; k <= j; k++ {
+			v := a[j][k]
+			for l := 0; l < k; l++ {
+				v -= res[j][l] * res[k][l]
+			}
+			if j == k {
+				if v <= 0 {
+					return nil
+				}
+				res[j][j] = math.Sqrt(v)
+			} else {
+				res[j][k] = v / res[k][k]
+			}
+		}
+	}
+	return res
+}
+
+// chiSquareQuantile approximates the quantile of the chi-square distribution with the degrees of freedom after
+// Wilson and Hilferty.
+func chiSquareQuantile(q float64, dof int) float64 {
+	z := math.Sqrt2 * math.Erfinv(2*q-1)
+	k := float64(dof)
+	v := 1 - 2/(9*k) + z*math.Sqrt(2/(9*k))
+	return k * v * v * v
+}
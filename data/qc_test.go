@@ -0,0 +1,59 @@
+package data
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestChiSquareQuantile(t *testing.T) {
+	// tabulated quantiles of 29.588 and 3.841
+	if q := chiSquareQuantile(0.999, 10); math.Abs(q-29.588) > 0.2 {
+		t.Errorf("expected 29.588, found %v", q)
+	}
+	if q := chiSquareQuantile(0.95, 1); math.Abs(q-3.841) > 0.1 {
+		t.Errorf("expected 3.841, found %v", q)
+	}
+}
+
+func TestInspect(t *testing.T) {
+	r := rand.New(rand.NewSource(42))
+	var recs []Record
+	for i := 0; i < 400; i++ {
+		clazz, centre := "water", 0.1
+		if i%2 == 1 {
+			clazz, centre = "pine", 0.5
+		}
+		xx := make([]float64, 3)
+		for j := range xx {
+			xx[j] = centre + 0.02*r.NormFloat64()
+		}
+		recs = append(recs, testRecord(clazz, "", i, i, xx...))
+	}
+	// a pine sample at water, a water sample far from both classes and a water sample at the clouds
+	recs[1].Data = []float64{0.1, 0.1, 0.1}
+	recs[2].Data = []float64{0.1, 0.1, 0.4}
+	recs[4].Data = []float64{0.88, 0.9, 0.91}
+	for i := 0; i < 20; i++ {
+		recs = append(recs, testRecord("cloud", "", 0, 0, 0.9+0.02*r.NormFloat64(), 0.9+0.02*r.NormFloat64(), 0.9+0.02*r.NormFloat64()))
+	}
+
+	opts := DefaultQCOptions()
+	opts.CloudClazz = "cloud"
+	flags := Inspect(recs, opts)
+	reasons := make(map[int][]string)
+	for _, f := range flags {
+		reasons[f.Index] = f.Reasons
+	}
+	for i, expected := range map[int][]string{1: {FlagOutlier, FlagBoundary}, 2: {FlagOutlier}, 4: {FlagOutlier, FlagCloudLike}} {
+		if len(reasons[i]) != len(expected) || reasons[i][0] != expected[0] || reasons[i][len(expected)-1] != expected[len(expected)-1] {
+			t.Errorf("sample %d: expected %v, found %v", i, expected, reasons[i])
+		}
+	}
+	if len(flags) > 10 {
+		t.Errorf("expected few samples flagged, found %d", len(flags))
+	}
+	if n := len(Exclude(recs, flags)); n != len(recs)-len(flags) {
+		t.Errorf("expected %d samples left, found %d", len(recs)-len(flags), n)
+	}
+}
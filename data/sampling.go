@@ -0,0 +1,165 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/nordicsense/gdal"
+	"github.com/nordicsense/landsat/dataset"
+)
+
+// SamplingOptions configure the pixels sampled around a field data coordinate.
+type SamplingOptions struct {
+	// Window is the odd side in pixels of the square window sampled around the coordinate, 1 for the pixel
+	// at the coordinate only.
+	Window int
+	// Radius samples the pixels with centres within the radius in metres of the coordinate instead of a square
+	// window, if positive.
+	Radius float64
+	// MaxVariance rejects windows whose variance of band values, averaged over the bands, exceeds it, if
+	// positive.
+	MaxVariance float64
+	// Temporal samples the temporal features of the pixels over the scenes of the season of the field data
+	// image, see Season, rather than the features of the image.
+	Temporal bool
+}
+
+// DefaultSamplingOptions returns the sampling of the pixel at the coordinate only.
+func DefaultSamplingOptions() SamplingOptions {
+	return SamplingOptions{Window: 1}
+}
+
+// Validate checks the window and the numeric options.
+func (o SamplingOptions) Validate() error {
+	if o.Window < 1 || o.Window%2 == 0 {
+		return fmt.Errorf("window %d must be odd and positive", o.Window)
+	}
+	if o.Radius < 0 || o.MaxVariance < 0 {
+		return fmt.Errorf("radius and variance must not be negative")
+	}
+	return nil
+}
+
+// offsets returns the pixel offsets of the window from the coordinate for the pixel size in metres, the
+// coordinate first.
+func (o SamplingOptions) offsets(pixelSize float64) [][2]int {
+	res := [][2]int{{0, 0}}
+	if o.Radius > 0 && pixelSize > 0 {
+		n := int(o.Radius / pixelSize)
+		for dy := -n; dy <= n; dy++ {
+			for dx := -n; dx <= n; dx++ {
+				if (dx != 0 || dy != 0) && math.Hypot(float64(dx), float64(dy))*pixelSize <= o.Radius {
+					res = append(res, [2]int{dx, dy})
+				}
+			}
+		}
+		return res
+	}
+	n := o.Window / 2
+	for dy := -n; dy <= n; dy++ {
+		for dx := -n; dx <= n; dx++ {
+			if dx != 0 || dy != 0 {
+				res = append(res, [2]int{dx, dy})
+			}
+		}
+	}
+	return res
+}
+
+// homogeneous tests the band values of the window pixels for the variance, averaged over the bands, not to
+// exceed the maximum. NaN values are left out.
+func (o SamplingOptions) homogeneous(window [][]float64) bool {
+	if o.MaxVariance <= 0 || len(window) < 2 {
+		return true
+	}
+	var total float64
+	for band := range window[0] {
+		var n, sum, sum2 float64
+		for _, xx := range window {
+			if v := xx[band]; !math.IsNaN(v) {
+				n++
+				sum += v
+				sum2 += v * v
+			}
+		}
+		if n > 1 {
+			mean := sum / n
+			total += (sum2 - n*mean*mean) / (n - 1)
+		}
+	}
+	return total/float64(len(window[0])) <= o.MaxVariance
+}
+
+// readPixels reads the bands of the 0-based pixels, inside the image, by raster block: every block holding
+// pixels is read once for all bands in a single call, rather than a call per band and pixel.
+func readPixels(ds gdal.Dataset, bands int, pixels [][2]int) (map[[2]int][]float64, error) {
+	xSize, ySize := ds.RasterXSize(), ds.RasterYSize()
+	bx, by := ds.RasterBand(1).BlockSize()
+	if bx < 1 || by < 1 {
+		bx, by = xSize, 1
+	}
+	byBlock := make(map[[2]int][][2]int)
+	var blocks [][2]int
+	for _, px := range pixels {
+		if px[0] < 0 || px[1] < 0 || px[0] >= xSize || px[1] >= ySize {
+			continue
+		}
+		block := [2]int{px[0] / bx, px[1] / by}
+		if _, ok := byBlock[block]; !ok {
+			blocks = append(blocks, block)
+		}
+		byBlock[block] = append(byBlock[block], px)
+	}
+	// in file order for sequential reads of striped and tiled images
+	sort.Slice(blocks, func(i, j int) bool {
+		return blocks[i][1] < blocks[j][1] || blocks[i][1] == blocks[j][1] && blocks[i][0] < blocks[j][0]
+	})
+
+	bandMap := make([]int, bands)
+	for i := range bandMap {
+		bandMap[i] = i + 1
+	}
+	res := make(map[[2]int][]float64)
+	var buf []float64
+	for _, block := range blocks {
+		x0, y0 := block[0]*bx, block[1]*by
+		w, h := bx, by
+		if x0+w > xSize {
+			w = xSize - x0
+		}
+		if y0+h > ySize {
+			h = ySize - y0
+		}
+		if cap(buf) < bands*w*h {
+			buf = make([]float64, bands*w*h)
+		}
+		buf = buf[:bands*w*h]
+		if err := ds.IO(gdal.Read, x0, y0, w, h, buf, w, h, bands, bandMap, 0, 0, 0); err != nil {
+			return nil, err
+		}
+		for _, px := range byBlock[block] {
+			xx := make([]float64, bands)
+			for band := range xx {
+				xx[band] = buf[band*w*h+(px[1]-y0)*w+px[0]-x0]
+			}
+			res[px] = xx
+		}
+	}
+	return res, nil
+}
+
+// readCoregistered reads the pixels of an image as readPixels does, failing unless its grid is that of the
+// image parameters.
+func readCoregistered(fileName string, ip *dataset.ImageParams, pixels [][2]int) (map[[2]int][]float64, error) {
+	r, err := dataset.OpenMultiBand(fileName)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if p := r.ImageParams(); p.XSize() != ip.XSize() || p.YSize() != ip.YSize() || p.Transform() != ip.Transform() {
+		return nil, fmt.Errorf("%s is not co-registered with the other images of the season", fileName)
+	}
+	// ugly performance workaround to get access to the raw reader
+	return readPixels(r.Reader(1).BreakGlass(), 7, pixels)
+}
@@ -0,0 +1,152 @@
+package data
+
+import (
+	"path"
+	"reflect"
+	"testing"
+
+	"github.com/nordicsense/gdal"
+	"github.com/nordicsense/landsat/dataset"
+)
+
+func TestOffsets(t *testing.T) {
+	opts := DefaultSamplingOptions()
+	if off := opts.offsets(30); len(off) != 1 || off[0] != [2]int{0, 0} {
+		t.Errorf("expected the coordinate only, found %v", off)
+	}
+	opts.Window = 3
+	if off := opts.offsets(30); len(off) != 9 || off[0] != [2]int{0, 0} {
+		t.Errorf("expected 9 pixels from the coordinate, found %v", off)
+	}
+	// the diagonal neighbours of 30 m pixels are 42.4 m away
+	opts.Radius = 40
+	if off := opts.offsets(30); len(off) != 5 {
+		t.Errorf("expected the coordinate and its direct neighbours, found %v", off)
+	}
+	opts.Radius = 45
+	if off := opts.offsets(30); len(off) != 9 {
+		t.Errorf("expected 9 pixels, found %v", off)
+	}
+}
+
+func TestHomogeneous(t *testing.T) {
+	opts := SamplingOptions{Window: 3, MaxVariance: 0.001}
+	if !opts.homogeneous([][]float64{{0.10, 0.20}, {0.11, 0.21}, {0.12, 0.19}}) {
+		t.Error("expected homogeneous window")
+	}
+	if opts.homogeneous([][]float64{{0.10, 0.20}, {0.11, 0.21}, {0.50, 0.19}}) {
+		t.Error("expected heterogeneous window")
+	}
+}
+
+// pixelValue encodes the band and 0-based pixel position into the value written at the pixel.
+func pixelValue(band, x, y int) float64 {
+	return float64(band*10000 + y*100 + x)
+}
+
+// writeTiled writes a multi-band GTiff of pixel values in tiles of 16 by 16 pixels, skipping without GDAL.
+func writeTiled(t *testing.T, fileName string, nx, ny, bands int) *dataset.ImageParams {
+	if driver, err := gdal.GetDriverByName(string(dataset.GTiff)); err != nil || driver.ShortName() != string(dataset.GTiff) {
+		t.Skip("GDAL GTiff driver not available")
+	}
+	ip := dataset.ImageParamsBuilder(nx, ny).DataType(gdal.Float64).
+		Transform(dataset.AffineTransform{500000, 30, 0, 7500000, 0, -30}).Projection(dataset.LandsatWKT).Build()
+	w, err := dataset.NewMultiBand(fileName, dataset.GTiff, bands, ip, "TILED=YES", "BLOCKXSIZE=16", "BLOCKYSIZE=16")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	row := make([]float64, nx)
+	for band := 0; band < bands; band++ {
+		for y := 0; y < ny; y++ {
+			for x := range row {
+				row[x] = pixelValue(band, x, y)
+			}
+			if err = dataset.WriteTyped(w.Writer(band+1), 0, y, dataset.Box{0, 0, nx, 1}, row); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	return ip
+}
+
+func TestReadPixels(t *testing.T) {
+	fileName := path.Join(t.TempDir(), "tiled.tif")
+	writeTiled(t, fileName, 40, 20, 3)
+	ds, err := gdal.Open(fileName, gdal.ReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+	if bx, by := ds.RasterBand(1).BlockSize(); bx != 16 || by != 16 {
+		t.Fatalf("expected tiles of 16x16, found %dx%d", bx, by)
+	}
+
+	inside := [][2]int{{0, 0}, {15, 15}, {16, 15}, {15, 16}, {16, 16}, {31, 0}, {32, 19}, {39, 19}, {16, 15}}
+	outside := [][2]int{{-1, 0}, {0, -1}, {40, 0}, {0, 20}, {40, 20}}
+	values, err := readPixels(ds, 3, append(append([][2]int{}, inside...), outside...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != len(inside)-1 {
+		t.Errorf("expected %d pixels, found %d", len(inside)-1, len(values))
+	}
+	for _, px := range inside {
+		expected := []float64{pixelValue(0, px[0], px[1]), pixelValue(1, px[0], px[1]), pixelValue(2, px[0], px[1])}
+		if actual := values[px]; !reflect.DeepEqual(actual, expected) {
+			t.Errorf("%v: expected %v, found %v", px, expected, actual)
+		}
+	}
+	for _, px := range outside {
+		if actual, ok := values[px]; ok {
+			t.Errorf("%v: expected no values outside the image, found %v", px, actual)
+		}
+	}
+}
+
+func TestTrainingData(t *testing.T) {
+	const image = "LC08_L1TP_186012_20200704_20200708_02_T1"
+	dir := t.TempDir()
+	ip := writeTiled(t, path.Join(dir, image+".tif"), 20, 20, 7)
+
+	coords := map[string]coordinateMap{
+		// overlapping windows at the image corner
+		"forest": {image: {{[2]int{1, 1}, "a/forest.asc"}, {[2]int{2, 1}, "a/forest.asc"}}},
+		// a window over four tiles
+		"water": {image: {{[2]int{17, 17}, "b/water.asc"}}},
+	}
+	recs, err := TrainingData(dir, `\.tif$`, coords, nil, func(string) bool { return true }, SamplingOptions{Window: 3}, PathThrough)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][][2]int{
+		"forest": {{0, 0}, {1, 0}, {0, 1}, {1, 1}, {2, 0}, {2, 1}},
+		"water":  {{16, 16}, {15, 15}, {16, 15}, {17, 15}, {15, 16}, {17, 16}, {15, 17}, {16, 17}, {17, 17}},
+	}
+	sources := map[string]string{"forest": "a/forest.asc", "water": "b/water.asc"}
+	actual := make(map[string][][2]int)
+	for _, rec := range recs {
+		px := [2]int{rec.Coords[0] - 1, rec.Coords[1] - 1}
+		actual[rec.Clazz] = append(actual[rec.Clazz], px)
+		if rec.Image != image || rec.Label != rec.Clazz || rec.Source != sources[rec.Clazz] || rec.Weight != 1 ||
+			rec.Acquired.Format("20060102") != "20200704" {
+			t.Errorf("%v: unexpected provenance %+v", px, rec)
+		}
+		for band, v := range rec.Data {
+			if v != pixelValue(band, px[0], px[1]) {
+				t.Errorf("%v: expected %v in band %d, found %v", px, pixelValue(band, px[0], px[1]), band+1, v)
+			}
+		}
+		lls, err := ip.PixelsDegrees([][2]int{px})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.LatLon != lls[0] {
+			t.Errorf("%v: expected location %v, found %v", px, lls[0], rec.LatLon)
+		}
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected pixels %v, found %v", expected, actual)
+	}
+}
@@ -0,0 +1,231 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+)
+
+// Strategies of splitting samples into training and test data. All but random keep the samples of a group,
+// being a field data file, an image or a spatial block, together so that neighbouring pixels do not end up in
+// both.
+const (
+	SplitRandom = "random"
+	SplitSource = "source"
+	SplitImage  = "image"
+	SplitBlock  = "block"
+)
+
+const kmPerDegree = 111.32
+
+// SplitOptions configure the split of samples into training and test data.
+type SplitOptions struct {
+	// Strategy is one of the split strategies, random if empty.
+	Strategy string
+	// Seed seeds the random order of samples and groups.
+	Seed int64
+	// TrainFraction is the fraction of the samples of a class used for training.
+	TrainFraction float64
+	// ClazzSize caps the samples of a class used for training and testing.
+	ClazzSize int
+	// TestSize caps the test samples of a class.
+	TestSize int
+	// BlockSize is the side of the spatial blocks in km.
+	BlockSize float64
+}
+
+// DefaultSplitOptions returns the random split of 80% of at most 40000 samples per class for training with at
+// most 3000 for testing, into blocks of 10 km if grouped by blocks.
+func DefaultSplitOptions() SplitOptions {
+	return SplitOptions{Strategy: SplitRandom, Seed: 42, TrainFraction: 0.8, ClazzSize: 40000, TestSize: 3000, BlockSize: 10}
+}
+
+// Validate checks the strategy and the numeric options.
+func (o SplitOptions) Validate() error {
+	switch o.Strategy {
+	case "", SplitRandom, SplitSource, SplitImage, SplitBlock:
+	default:
+		return fmt.Errorf("unknown split strategy %s, expected random, source, image or block", o.Strategy)
+	}
+	if o.TrainFraction <= 0 || o.TrainFraction >= 1 {
+		return fmt.Errorf("train fraction %v must be between 0 and 1", o.TrainFraction)
+	}
+	if o.ClazzSize <= 0 || o.TestSize <= 0 || o.Strategy == SplitBlock && o.BlockSize <= 0 {
+		return fmt.Errorf("class size, test size and block size must be positive")
+	}
+	return nil
+}
+
+// Fold is the training and test data of a cross-validation fold.
+type Fold struct {
+	Train, Test []Record
+}
+
+// Split splits the samples into training and test data. The random strategy subsamples the classes as
+// Subsample does. The others take groups in random order into test data as long as the class most of their
+// samples are of lacks test samples, the rest into training data, and cap the classes thereafter.
+func Split(recs []Record, clazzId map[string]int, opts SplitOptions) (train, test []Record) {
+	r := rand.New(rand.NewSource(opts.Seed))
+	recs = ordered(recs)
+	if opts.Strategy == "" || opts.Strategy == SplitRandom {
+		return Subsample(recs, clazzId, opts.ClazzSize, opts.TestSize, r, opts.TrainFraction)
+	}
+	wanted := make(map[string]int)
+	for _, rec := range recs {
+		wanted[rec.Clazz]++
+	}
+	for clazz, n := range wanted {
+		wanted[clazz] = int(float64(n) * (1 - opts.TrainFraction))
+	}
+	for _, g := range opts.groups(recs, r) {
+		if c := dominant(g); wanted[c] > 0 {
+			wanted[c] -= len(g)
+			test = append(test, g...)
+		} else {
+			train = append(train, g...)
+		}
+	}
+	return opts.limit(train, clazzId, true, r), opts.limit(test, clazzId, false, r)
+}
+
+// KFold splits the samples into k folds for cross-validation, each fold being test data once with the other
+// folds as training data. Groups, single samples with the random strategy, go in random order to the fold
+// with the fewest samples of the class most of their samples are of; the classes are capped as by Split.
+func KFold(recs []Record, clazzId map[string]int, opts SplitOptions, k int) []Fold {
+	r := rand.New(rand.NewSource(opts.Seed))
+	parts := make([][]Record, k)
+	counts := make([]map[string]int, k)
+	for i := range counts {
+		counts[i] = make(map[string]int)
+	}
+	for _, g := range opts.groups(ordered(recs), r) {
+		c := dominant(g)
+		best := 0
+		for i := 1; i < k; i++ {
+			if counts[i][c] < counts[best][c] {
+				best = i
+			}
+		}
+		parts[best] = append(parts[best], g...)
+		counts[best][c] += len(g)
+	}
+	res := make([]Fold, k)
+	for i := range parts {
+		var train []Record
+		for j, part := range parts {
+			if j != i {
+				train = append(train, part...)
+			}
+		}
+		res[i] = Fold{Train: opts.limit(train, clazzId, true, r), Test: opts.limit(parts[i], clazzId, false, r)}
+	}
+	return res
+}
+
+// ordered sorts the samples by provenance for splits to depend on the seed only.
+func ordered(recs []Record) []Record {
+	res := append([]Record{}, recs...)
+	sort.SliceStable(res, func(i, j int) bool {
+		a, b := res[i], res[j]
+		switch {
+		case a.Image != b.Image:
+			return a.Image < b.Image
+		case a.Source != b.Source:
+			return a.Source < b.Source
+		case a.Coords[1] != b.Coords[1]:
+			return a.Coords[1] < b.Coords[1]
+		}
+		return a.Coords[0] < b.Coords[0]
+	})
+	return res
+}
+
+// group returns the key of the group of the i-th sample.
+func (o SplitOptions) group(rec Record, i int) string {
+	switch o.Strategy {
+	case SplitSource:
+		return rec.Source
+	case SplitImage:
+		return rec.Image
+	case SplitBlock:
+		// blocks of roughly equal area in km, narrowing in degrees of longitude towards the pole
+		lat, lon := rec.LatLon[0], rec.LatLon[1]
+		y := math.Floor(lat * kmPerDegree / o.BlockSize)
+		x := math.Floor(lon * kmPerDegree * math.Cos(lat*math.Pi/180) / o.BlockSize)
+		return fmt.Sprintf("%.0f/%.0f", y, x)
+	}
+	return strconv.Itoa(i)
+}
+
+// groups returns the samples by group in random order.
+func (o SplitOptions) groups(recs []Record, r *rand.Rand) [][]Record {
+	var keys []string
+	byKey := make(map[string][]Record)
+	for i, rec := range recs {
+		key := o.group(rec, i)
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+		}
+		byKey[key] = append(byKey[key], rec)
+	}
+	res := make([][]Record, len(keys))
+	for i, key := range keys {
+		res[i] = byKey[key]
+	}
+	r.Shuffle(len(res), func(i, j int) { res[i], res[j] = res[j], res[i] })
+	return res
+}
+
+// dominant returns the class most of the samples are of.
+func dominant(recs []Record) string {
+	counts := make(map[string]int)
+	res := ""
+	for _, rec := range recs {
+		counts[rec.Clazz]++
+		if counts[rec.Clazz] > counts[res] || counts[rec.Clazz] == counts[res] && rec.Clazz < res {
+			res = rec.Clazz
+		}
+	}
+	return res
+}
+
+// sortedClazzes returns the classes in order of their ids, for random selections per class to be reproducible
+// by the seed.
+func sortedClazzes(clazzId map[string]int) []string {
+	var res []string
+	for clazz := range clazzId {
+		res = append(res, clazz)
+	}
+	sort.Slice(res, func(i, j int) bool { return clazzId[res[i]] < clazzId[res[j]] })
+	return res
+}
+
+// limit caps the samples of every class at the training or test share of the class size, the test samples
+// also at the test size, selecting at random.
+func (o SplitOptions) limit(recs []Record, clazzId map[string]int, training bool, r *rand.Rand) []Record {
+	size := int(float64(o.ClazzSize) * o.TrainFraction)
+	if !training {
+		size = int(float64(o.ClazzSize) * (1 - o.TrainFraction))
+		if o.TestSize < size {
+			size = o.TestSize
+		}
+	}
+	byClazz := make(map[string][]Record)
+	for _, rec := range recs {
+		byClazz[rec.Clazz] = append(byClazz[rec.Clazz], rec)
+	}
+	var res []Record
+	for _, clazz := range sortedClazzes(clazzId) {
+		xx := byClazz[clazz]
+		if len(xx) <= size {
+			res = append(res, xx...)
+			continue
+		}
+		for _, i := range r.Perm(len(xx))[:size] {
+			res = append(res, xx[i])
+		}
+	}
+	return res
+}
@@ -0,0 +1,99 @@
+package data
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func splitRecords() []Record {
+	var res []Record
+	for i := 0; i < 400; i++ {
+		clazz := []string{"water", "pine"}[i%2]
+		rec := testRecord(clazz, fmt.Sprintf("LT05_L1TP_188012_1986072%d", i%5), i, i%7)
+		rec.Source = fmt.Sprintf("%d/%s.asc", i%10, clazz)
+		rec.LatLon = [2]float64{68 + float64(i%4)*0.2, 33 + float64(i%3)*0.5}
+		res = append(res, rec)
+	}
+	return res
+}
+
+func TestSplit(t *testing.T) {
+	recs := splitRecords()
+	for _, strategy := range []string{SplitSource, SplitImage, SplitBlock} {
+		opts := DefaultSplitOptions()
+		opts.Strategy = strategy
+		train, test := Split(recs, testClazzId, opts)
+		if len(train)+len(test) != len(recs) || len(test) == 0 || len(train) <= len(test) {
+			t.Errorf("%s: unexpected split of %d into %d and %d", strategy, len(recs), len(train), len(test))
+		}
+		inTest := make(map[string]bool)
+		for i, rec := range test {
+			inTest[opts.group(rec, i)] = true
+		}
+		for i, rec := range train {
+			if g := opts.group(rec, i); inTest[g] {
+				t.Errorf("%s: group %s in training and test data", strategy, g)
+			}
+		}
+		again, _ := Split(recs, testClazzId, opts)
+		if !reflect.DeepEqual(train, again) {
+			t.Errorf("%s: expected the same split for the same seed", strategy)
+		}
+	}
+
+	opts := DefaultSplitOptions()
+	opts.ClazzSize, opts.TestSize = 100, 10
+	opts.Strategy = SplitImage
+	train, test := Split(recs, testClazzId, opts)
+	if len(train) != 2*80 || len(test) != 2*10 {
+		t.Errorf("expected classes capped at 80 and 10 samples, found %d and %d", len(train), len(test))
+	}
+}
+
+func TestSplitRandomSeed(t *testing.T) {
+	recs := splitRecords()
+	opts := DefaultSplitOptions()
+	train, test := Split(recs, testClazzId, opts)
+	if len(train) == 0 || len(test) == 0 {
+		t.Fatalf("unexpected split of %d into %d and %d", len(recs), len(train), len(test))
+	}
+	// classes are visited in map order unless sorted, so a few repetitions catch that
+	for i := 0; i < 10; i++ {
+		again, againTest := Split(recs, testClazzId, opts)
+		if !reflect.DeepEqual(train, again) || !reflect.DeepEqual(test, againTest) {
+			t.Fatal("expected the same split for the same seed")
+		}
+	}
+}
+
+func TestKFold(t *testing.T) {
+	recs := splitRecords()
+	opts := DefaultSplitOptions()
+	opts.Strategy = SplitSource
+	folds := KFold(recs, testClazzId, opts, 5)
+	seen := make(map[[2]int]int)
+	for i, fold := range folds {
+		if len(fold.Test) == 0 || len(fold.Train)+len(fold.Test) != len(recs) {
+			t.Errorf("fold %d: unexpected sizes %d and %d", i, len(fold.Train), len(fold.Test))
+		}
+		sources := make(map[string]bool)
+		for _, rec := range fold.Test {
+			seen[rec.Coords]++
+			sources[rec.Source] = true
+		}
+		for _, rec := range fold.Train {
+			if sources[rec.Source] {
+				t.Errorf("fold %d: source %s in training and test data", i, rec.Source)
+			}
+		}
+	}
+	if len(seen) != len(recs) {
+		t.Errorf("expected every sample in test data once, found %d", len(seen))
+	}
+	for coords, n := range seen {
+		if n != 1 {
+			t.Errorf("sample %v in test data %d times", coords, n)
+		}
+	}
+}
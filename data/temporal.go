@@ -0,0 +1,144 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/nordicsense/landsat/product"
+)
+
+// TemporalMetrics are the metrics of every feature over the scenes of a season.
+var TemporalMetrics = []string{"median", "p10", "p90", "amplitude"}
+
+// NTemporalVars is the number of temporal features: the metrics of every feature and the NDVI slope.
+const NTemporalVars = NVars*4 + 1
+
+// ndviVar is the index of the NDVI among the features.
+const ndviVar = 6
+
+// TemporalClazzes names the temporal features, e.g. ndvi_median, in the order of Temporal.
+var TemporalClazzes = func() []string {
+	var res []string
+	for _, name := range Clazzes {
+		for _, metric := range TemporalMetrics {
+			res = append(res, name+"_"+metric)
+		}
+	}
+	return append(res, Clazzes[ndviVar]+"_slope")
+}()
+
+// FeatureNames returns the names of the features of a sample, temporal or of a single scene.
+func FeatureNames(n int) []string {
+	if n == NTemporalVars {
+		return TemporalClazzes
+	}
+	return Clazzes
+}
+
+// Temporal computes the temporal features of a pixel from its features in the scenes of a season acquired on
+// the days of year: the median, 10th and 90th percentile and amplitude, being the range, of every feature and
+// the least-squares slope of the NDVI per day. Scenes with NaN features, e.g. masked or nodata, are left out;
+// the features are NaN without any scene left and the slope is 0 with a single one.
+func Temporal(series [][]float64, days []float64) []float64 {
+	res := make([]float64, NTemporalVars)
+	var valid [][]float64
+	var validDays []float64
+	for i, xx := range series {
+		ok := true
+		for _, v := range xx {
+			ok = ok && !math.IsNaN(v)
+		}
+		if ok {
+			valid = append(valid, xx)
+			validDays = append(validDays, days[i])
+		}
+	}
+	if len(valid) == 0 {
+		for i := range res {
+			res[i] = math.NaN()
+		}
+		return res
+	}
+	vv := make([]float64, len(valid))
+	for j := 0; j < NVars; j++ {
+		for i, xx := range valid {
+			vv[i] = xx[j]
+		}
+		sort.Float64s(vv)
+		res[4*j] = percentile(vv, 0.5)
+		res[4*j+1] = percentile(vv, 0.1)
+		res[4*j+2] = percentile(vv, 0.9)
+		res[4*j+3] = vv[len(vv)-1] - vv[0]
+	}
+	var meanDay, meanNDVI float64
+	for i, xx := range valid {
+		meanDay += validDays[i] / float64(len(valid))
+		meanNDVI += xx[ndviVar] / float64(len(valid))
+	}
+	var cov, vari float64
+	for i, xx := range valid {
+		cov += (validDays[i] - meanDay) * (xx[ndviVar] - meanNDVI)
+		vari += (validDays[i] - meanDay) * (validDays[i] - meanDay)
+	}
+	if vari > 0 {
+		res[NTemporalVars-1] = cov / vari
+	}
+	return res
+}
+
+// percentile interpolates the percentile linearly between the sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	pos := p * float64(len(sorted)-1)
+	i := int(pos)
+	if i+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[i] + (pos-float64(i))*(sorted[i+1]-sorted[i])
+}
+
+// Season returns the images of the same WRS-2 path/row acquired in the same year as the scene, each scene once
+// and in order of acquisition, e.g. the scenes of a growing season selected by date or day of year.
+func Season(fileNames []string, scene product.ID) []string {
+	var ids []product.ID
+	var res []string
+	for _, fileName := range fileNames {
+		id, err := product.ParseID(fileName)
+		if err != nil || id.PathRow() != scene.PathRow() || id.Acquired.Year() != scene.Acquired.Year() {
+			continue
+		}
+		dup := false
+		for _, other := range ids {
+			dup = dup || other.SameScene(id)
+		}
+		if !dup {
+			ids = append(ids, id)
+			res = append(res, fileName)
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		a, _ := product.ParseID(res[i])
+		b, _ := product.ParseID(res[j])
+		return a.Acquired.Before(b.Acquired)
+	})
+	return res
+}
+
+// Seasons groups the images into seasons as Season does, in order of the first image of a season seen.
+// Images without product identifier are left out.
+func Seasons(fileNames []string) [][]string {
+	seen := make(map[string]bool)
+	var res [][]string
+	for _, fileName := range fileNames {
+		id, err := product.ParseID(fileName)
+		if err != nil {
+			continue
+		}
+		key := fmt.Sprintf("%s_%d", id.PathRow(), id.Acquired.Year())
+		if !seen[key] {
+			seen[key] = true
+			res = append(res, Season(fileNames, id))
+		}
+	}
+	return res
+}
@@ -0,0 +1,64 @@
+package data
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/nordicsense/landsat/product"
+)
+
+func TestTemporal(t *testing.T) {
+	var series [][]float64
+	days := []float64{160, 180, 200, 220, 240}
+	for i, day := range days {
+		xx := make([]float64, NVars)
+		for j := range xx {
+			xx[j] = float64(i) / 10
+		}
+		xx[ndviVar] = 0.5 + (day-160)/1000
+		series = append(series, xx)
+	}
+	series = append(series, []float64{math.NaN()})
+	days = append(days, 260)
+
+	res := Temporal(series, days)
+	if len(res) != NTemporalVars || len(TemporalClazzes) != NTemporalVars {
+		t.Fatalf("expected %d features, found %d", NTemporalVars, len(res))
+	}
+	expected := []float64{0.2, 0.04, 0.36, 0.4}
+	for i, v := range expected {
+		if math.Abs(res[i]-v) > 1e-9 {
+			t.Errorf("%s: expected %v, found %v", TemporalClazzes[i], v, res[i])
+		}
+	}
+	if slope := res[NTemporalVars-1]; math.Abs(slope-0.001) > 1e-9 || TemporalClazzes[NTemporalVars-1] != "ndvi_slope" {
+		t.Errorf("expected NDVI slope of 0.001 per day, found %v", slope)
+	}
+	if res := Temporal([][]float64{{math.NaN()}}, []float64{160}); !math.IsNaN(res[0]) {
+		t.Errorf("expected NaN features without valid scene, found %v", res)
+	}
+}
+
+func TestSeasons(t *testing.T) {
+	fileNames := []string{
+		"a/LT05_L1TP_188012_19860728.tiff",
+		"b/LT05_L1TP_188012_19860610.tiff",
+		"b/LT05_L2SP_188012_19860728.tiff",
+		"a/LT05_L1TP_188012_19870728.tiff",
+		"a/LE07_L1TP_190011_19860815.tiff",
+		"a/LT05_L1TP_188012_19860901.tiff",
+	}
+	expected := [][]string{
+		{"b/LT05_L1TP_188012_19860610.tiff", "a/LT05_L1TP_188012_19860728.tiff", "a/LT05_L1TP_188012_19860901.tiff"},
+		{"a/LT05_L1TP_188012_19870728.tiff"},
+		{"a/LE07_L1TP_190011_19860815.tiff"},
+	}
+	if seasons := Seasons(fileNames); !reflect.DeepEqual(seasons, expected) {
+		t.Errorf("expected %v, found %v", expected, seasons)
+	}
+	id, _ := product.ParseID("LT05_L1TP_188012_19860901")
+	if season := Season(fileNames, id); !reflect.DeepEqual(season, expected[0]) {
+		t.Errorf("expected %v, found %v", expected[0], season)
+	}
+}
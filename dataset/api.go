@@ -10,14 +10,18 @@ type Box [4]int
 const (
 	GTiff Driver = "GTiff"
 	domain = ""
+
+	bandDescriptionKey = "DESCRIPTION"
 )
 
 type UniBandReader interface {
 	ImageParams() *ImageParams
 	RasterParams() *RasterParams
+	DatasetParams() *DatasetParams
 	Read(x, y int) (float64, error)
 	ReadAtLatLon(ll LatLon) (float64, error)
 	ReadBlock(x, y int, box Box) ([]float64, error)
+	ReadBlockInto(x, y int, box Box, buffer []float64) error
 	Close()
 	BreakGlass() gdal.Dataset
 }
@@ -26,6 +30,8 @@ type UniBandWriter interface {
 	ImageParams() *ImageParams
 	RasterParams() *RasterParams
 	SetRasterParams(rp *RasterParams) error
+	DatasetParams() *DatasetParams
+	SetDatasetParams(dp *DatasetParams) error
 	Write(x, y int, v float64) error
 	WriteAtLatLon(ll LatLon, v float64) error
 	WriteBlock(x, y int, box Box, buffer []float64) error
@@ -35,6 +41,7 @@ type UniBandWriter interface {
 
 type MultiBandReader interface {
 	ImageParams() *ImageParams
+	DatasetParams() *DatasetParams
 	Bands() int
 	Reader(band int) UniBandReader
 	Close()
@@ -42,6 +49,8 @@ type MultiBandReader interface {
 
 type MultiBandWriter interface {
 	ImageParams() *ImageParams
+	DatasetParams() *DatasetParams
+	SetDatasetParams(dp *DatasetParams) error
 	Bands() int
 	Writer(band int) UniBandWriter
 	Close()
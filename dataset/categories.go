@@ -0,0 +1,113 @@
+package dataset
+
+import (
+	"sort"
+
+	"github.com/nordicsense/gdal"
+)
+
+// Category describes a value of a categorical raster such as a classification map: its name and RGBA colour.
+type Category struct {
+	Name  string
+	Color [4]uint8
+}
+
+var ratColumns = []struct {
+	name  string
+	typ   gdal.RATFieldType
+	usage gdal.RATFieldUsage
+}{
+	{"Value", gdal.GFT_Integer, gdal.GFU_MinMax},
+	{"Name", gdal.GFT_String, gdal.GFU_Name},
+	{"Red", gdal.GFT_Integer, gdal.GFU_Red},
+	{"Green", gdal.GFT_Integer, gdal.GFU_Green},
+	{"Blue", gdal.GFT_Integer, gdal.GFU_Blue},
+	{"Alpha", gdal.GFT_Integer, gdal.GFU_Alpha},
+}
+
+// writeCategories stores the legend as category names, a raster attribute table and, for Byte and UInt16 bands
+// that support it, a colour table. Negative values, e.g. the change marker, are only present in the attribute
+// table.
+func writeCategories(rb gdal.RasterBand, dt gdal.DataType, categories map[int]Category) error {
+	var values []int
+	for v := range categories {
+		values = append(values, v)
+	}
+	sort.Ints(values)
+	maxValue := values[len(values)-1]
+
+	if maxValue >= 0 {
+		names := make([]string, maxValue+1)
+		for v, c := range categories {
+			if v >= 0 {
+				names[v] = c.Name
+			}
+		}
+		if err := rb.SetRasterCategoryNames(names); err != nil {
+			return err
+		}
+	}
+
+	if maxValue >= 0 && (dt == gdal.Byte || dt == gdal.UInt16) {
+		ct := gdal.CreateColorTable(gdal.PI_RGB)
+		defer ct.Destroy()
+		for v := 0; v <= maxValue; v++ {
+			var ce gdal.ColorEntry
+			if c, ok := categories[v]; ok {
+				ce.Set(uint(c.Color[0]), uint(c.Color[1]), uint(c.Color[2]), uint(c.Color[3]))
+			}
+			ct.SetEntry(v, ce)
+		}
+		if err := rb.SetColorTable(ct); err != nil {
+			return err
+		}
+	}
+
+	rat := gdal.CreateRasterAttributeTable()
+	defer rat.Destroy()
+	for _, col := range ratColumns {
+		if err := rat.CreateColumn(col.name, col.typ, col.usage); err != nil {
+			return err
+		}
+	}
+	rat.SetRowCount(len(values))
+	for row, v := range values {
+		c := categories[v]
+		rat.SetValueAsInt(row, 0, v)
+		rat.SetValueAsString(row, 1, c.Name)
+		for i := 0; i < 4; i++ {
+			rat.SetValueAsInt(row, i+2, int(c.Color[i]))
+		}
+	}
+	return rb.SetDefaultRAT(rat)
+}
+
+// readCategories restores the legend written by writeCategories, it returns nil for non-categorical bands.
+func readCategories(rb gdal.RasterBand) map[int]Category {
+	names := rb.CategoryNames()
+	if len(names) == 0 {
+		return nil
+	}
+	res := make(map[int]Category)
+	rat := rb.GetDefaultRAT()
+	var cols [6]int
+	for i, col := range ratColumns {
+		if cols[i] = rat.ColOfUsage(col.usage); cols[i] < 0 {
+			// no attribute table written by us, fall back to category names only
+			for v, name := range names {
+				if name != "" {
+					res[v] = Category{Name: name}
+				}
+			}
+			return res
+		}
+	}
+	for row := 0; row < rat.RowCount(); row++ {
+		c := Category{Name: rat.ValueAsString(row, cols[1])}
+		for i := 0; i < 4; i++ {
+			c.Color[i] = uint8(rat.ValueAsInt(row, cols[i+2]))
+		}
+		res[rat.ValueAsInt(row, cols[0])] = c
+	}
+	return res
+}
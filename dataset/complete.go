@@ -0,0 +1,124 @@
+package dataset
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/nordicsense/gdal"
+	"github.com/nordicsense/landsat/io"
+)
+
+const (
+	// CompleteKey is the dataset metadata item marking a product as completely written, holding the time.
+	CompleteKey = "COMPLETE"
+	// RowsDoneKey is the dataset metadata item of incomplete products holding the number of rows written, from
+	// which writing can resume.
+	RowsDoneKey = "ROWS_DONE"
+
+	partialPrefix = ".partial."
+	auxSuffix     = ".aux.xml"
+)
+
+// PartialName returns the name of the temporary file a product is written to until complete. It is stable for
+// writing to resume after an interruption.
+func PartialName(fileName string) string {
+	return path.Join(path.Dir(fileName), partialPrefix+path.Base(fileName))
+}
+
+// MarkComplete stamps the product as completely written and clears the checkpoint, which the GDAL bindings
+// cannot remove.
+func MarkComplete(fileName string) error {
+	ds, err := gdal.Open(fileName, gdal.Update)
+	if err != nil {
+		return err
+	}
+	defer ds.Close()
+	if ds.MetadataItem(RowsDoneKey, domain) != "" {
+		if err = ds.SetMetadataItem(RowsDoneKey, "", domain); err != nil {
+			return err
+		}
+	}
+	return ds.SetMetadataItem(CompleteKey, time.Now().UTC().Format(time.RFC3339), domain)
+}
+
+// Completed returns when the product was completely written, false for missing or incomplete products.
+func Completed(fileName string) (time.Time, bool) {
+	if _, err := io.Stat(fileName); err != nil {
+		return time.Time{}, false
+	}
+	ds, err := gdal.Open(fileName, gdal.ReadOnly)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer ds.Close()
+	t, err := time.Parse(time.RFC3339, ds.MetadataItem(CompleteKey, domain))
+	return t, err == nil
+}
+
+// UpToDate tells whether the product is complete and none of the input files, or files under input
+// directories, was modified since. Products and inputs may be remote.
+func UpToDate(fileName string, inputs ...string) bool {
+	completed, ok := Completed(fileName)
+	if !ok {
+		return false
+	}
+	for _, input := range inputs {
+		if modified, err := io.Modified(input); err != nil || modified.After(completed) {
+			return false
+		}
+	}
+	return true
+}
+
+// Commit renames the complete temporary file of a product, together with its auxiliary metadata, to the
+// product name.
+func Commit(fileName string) error {
+	partial := PartialName(fileName)
+	if _, err := os.Stat(partial + auxSuffix); err == nil {
+		if err = os.Rename(partial+auxSuffix, fileName+auxSuffix); err != nil {
+			return err
+		}
+	} else {
+		_ = os.Remove(fileName + auxSuffix)
+	}
+	return os.Rename(partial, fileName)
+}
+
+// Checkpoint records the number of rows written so far for writing to resume from and flushes the rows
+// together with that number to disk.
+func Checkpoint(w UniBandWriter, rows int) error {
+	ds := w.BreakGlass()
+	if err := ds.SetMetadataItem(RowsDoneKey, strconv.Itoa(rows), domain); err != nil {
+		return err
+	}
+	ds.FlushCache()
+	return nil
+}
+
+// Resume opens an incomplete product of the same image parameters for update and returns the number of rows
+// written before the last checkpoint. It returns no writer when there is nothing to resume.
+func Resume(fileName string, ip *ImageParams) (UniBandWriter, int, error) {
+	if _, err := os.Stat(fileName); err != nil {
+		return nil, 0, nil
+	}
+	ds, err := gdal.Open(fileName, gdal.Update)
+	if err != nil {
+		return nil, 0, nil
+	}
+	rows, err := strconv.Atoi(ds.MetadataItem(RowsDoneKey, domain))
+	complete := ds.MetadataItem(CompleteKey, domain) != ""
+	if err != nil || complete || ds.RasterCount() != 1 || ds.RasterXSize() != ip.XSize() ||
+		ds.RasterYSize() != ip.YSize() || AffineTransform(ds.GeoTransform()) != ip.Transform() ||
+		ds.RasterBand(1).RasterDataType() != ip.DataType() {
+		ds.Close()
+		return nil, 0, nil
+	}
+	w, err := openSingleBand(ds, 1, readDatasetParams(ds))
+	if err != nil {
+		ds.Close()
+		return nil, 0, err
+	}
+	return w, rows, nil
+}
@@ -0,0 +1,112 @@
+package dataset
+
+import (
+	"path"
+	"testing"
+
+	"github.com/nordicsense/gdal"
+)
+
+func TestInheritable(t *testing.T) {
+	dp := DatasetParamsBuilder().
+		Metadata("SENSOR", "OLI").
+		Metadata(CompleteKey, "2020-01-01T00:00:00Z").
+		Metadata(RowsDoneKey, "10").
+		Metadata(ProvenanceKey, "{}").
+		Build()
+	res := dp.Inheritable()
+	if v, ok := res.MetadataItem("SENSOR"); !ok || v != "OLI" {
+		t.Errorf("expected the sensor to be inherited, found %q", v)
+	}
+	for _, key := range []string{CompleteKey, RowsDoneKey, ProvenanceKey} {
+		if _, ok := res.MetadataItem(key); ok {
+			t.Errorf("expected %s not to be inherited", key)
+		}
+		if _, ok := dp.MetadataItem(key); !ok {
+			t.Errorf("expected %s to be kept in the original", key)
+		}
+	}
+}
+
+func TestResumeFromCompleteInput(t *testing.T) {
+	if driver, err := gdal.GetDriverByName(string(GTiff)); err != nil || driver.ShortName() != string(GTiff) {
+		t.Skip("GDAL GTiff driver not available")
+	}
+	dir := t.TempDir()
+	ip := ImageParamsBuilder(4, 4).DataType(gdal.Byte).Build()
+	rp := RasterParamsBuilder().Build()
+	row := []uint8{1, 2, 3, 4}
+	writeRows := func(w UniBandWriter, from, to int) {
+		for y := from; y < to; y++ {
+			if err := WriteTyped(w, 0, y, Box{0, 0, 4, 1}, row); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	input := path.Join(dir, "input.tif")
+	w, err := NewUniBand(input, GTiff, ip, rp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.SetDatasetParams(DatasetParamsBuilder().Metadata("SENSOR", "OLI").Metadata(ProvenanceKey, "{}").Build()); err != nil {
+		t.Fatal(err)
+	}
+	writeRows(w, 0, 4)
+	w.Close()
+	if err = MarkComplete(input); err != nil {
+		t.Fatal(err)
+	}
+	r, err := OpenUniBand(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	// interrupted after a checkpoint at half of the rows
+	output := PartialName(path.Join(dir, "output.tif"))
+	if w, _, err = Resume(output, ip); err != nil || w != nil {
+		t.Fatalf("expected nothing to resume, found %v, %v", w, err)
+	}
+	if w, err = NewUniBand(output, GTiff, ip, rp); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.SetDatasetParams(r.DatasetParams().Inheritable()); err != nil {
+		t.Fatal(err)
+	}
+	writeRows(w, 0, 2)
+	if err = Checkpoint(w, 2); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+
+	w, done, err := Resume(output, ip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w == nil || done != 2 {
+		t.Fatalf("expected to resume after 2 rows, found %v, %d", w, done)
+	}
+	if v, ok := w.DatasetParams().MetadataItem("SENSOR"); !ok || v != "OLI" {
+		t.Errorf("expected the sensor to be inherited, found %q", v)
+	}
+	writeRows(w, done, 4)
+	w.Close()
+	if err = MarkComplete(output); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := Completed(output); !ok {
+		t.Errorf("expected the resumed product to be complete")
+	}
+	if w, _, err = Resume(output, ip); err != nil || w != nil {
+		t.Errorf("expected nothing to resume of a complete product, found %v, %v", w, err)
+	}
+	ds, err := gdal.Open(output, gdal.ReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ds.Close()
+	if v := ds.MetadataItem(RowsDoneKey, domain); v != "" {
+		t.Errorf("expected the checkpoint cleared, found %s", v)
+	}
+}
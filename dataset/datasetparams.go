@@ -0,0 +1,135 @@
+package dataset
+
+import (
+	"strings"
+
+	"github.com/nordicsense/gdal"
+)
+
+// MetadataDomains lists the dataset-level metadata domains read when opening a dataset.
+var MetadataDomains = []string{domain}
+
+// ProvenanceKey is the dataset metadata item holding the provenance record of a product.
+const ProvenanceKey = "PROVENANCE"
+
+// productKeys are the metadata items describing a dataset as a product rather than the scene it shows.
+var productKeys = []string{CompleteKey, RowsDoneKey, ProvenanceKey}
+
+type DatasetParams struct {
+	metadata map[string]map[string]string
+}
+
+func (p *DatasetParams) copy() *DatasetParams {
+	res := &DatasetParams{metadata: make(map[string]map[string]string)}
+	for d, items := range p.metadata {
+		res.metadata[d] = make(map[string]string)
+		for k, v := range items {
+			res.metadata[d][k] = v
+		}
+	}
+	return res
+}
+
+func (p *DatasetParams) ToBuilder() *datasetParamsBuilder {
+	return &datasetParamsBuilder{DatasetParams: p.copy()}
+}
+
+// Domains lists the metadata domains present.
+func (p *DatasetParams) Domains() []string {
+	var res []string
+	for d := range p.metadata {
+		res = append(res, d)
+	}
+	return res
+}
+
+// Metadata returns the metadata of the default domain.
+func (p *DatasetParams) Metadata() map[string]string {
+	return p.DomainMetadata(domain)
+}
+
+// DomainMetadata returns the metadata of a given domain.
+func (p *DatasetParams) DomainMetadata(d string) map[string]string {
+	res := make(map[string]string)
+	for k, v := range p.metadata[d] {
+		res[k] = v
+	}
+	return res
+}
+
+// MetadataItem returns a metadata item of the default domain.
+func (p *DatasetParams) MetadataItem(key string) (string, bool) {
+	v, ok := p.metadata[domain][key]
+	return v, ok
+}
+
+// Inheritable returns the metadata carried over to products derived from the dataset, such as acquisition date
+// and sensor, without the completion, checkpoint and provenance of the dataset itself: otherwise a partial
+// product would look complete and never be resumed.
+func (p *DatasetParams) Inheritable() *DatasetParams {
+	res := p.copy()
+	for _, key := range productKeys {
+		delete(res.metadata[domain], key)
+	}
+	return res
+}
+
+func DatasetParamsBuilder() *datasetParamsBuilder {
+	return &datasetParamsBuilder{DatasetParams: &DatasetParams{metadata: make(map[string]map[string]string)}}
+}
+
+type datasetParamsBuilder struct {
+	*DatasetParams
+}
+
+// Metadata sets a metadata item in the default domain.
+func (dpb *datasetParamsBuilder) Metadata(key, value string) *datasetParamsBuilder {
+	return dpb.DomainMetadata(domain, key, value)
+}
+
+// DomainMetadata sets a metadata item in a given domain.
+func (dpb *datasetParamsBuilder) DomainMetadata(d, key, value string) *datasetParamsBuilder {
+	items, ok := dpb.metadata[d]
+	if !ok {
+		items = make(map[string]string)
+		dpb.metadata[d] = items
+	}
+	items[key] = value
+	return dpb
+}
+
+func (dpb *datasetParamsBuilder) Build() *DatasetParams {
+	return dpb.DatasetParams.copy()
+}
+
+func readDatasetParams(ds gdal.Dataset) *DatasetParams {
+	dpb := DatasetParamsBuilder()
+	for _, d := range MetadataDomains {
+		for k, v := range parseMetadata(ds.Metadata(d)) {
+			dpb = dpb.DomainMetadata(d, k, v)
+		}
+	}
+	return dpb.Build()
+}
+
+func writeDatasetParams(ds gdal.Dataset, dp *DatasetParams) error {
+	for d, items := range dp.metadata {
+		for k, v := range items {
+			if err := ds.SetMetadataItem(k, v, d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseMetadata converts GDAL KEY=VALUE metadata lists into a map.
+func parseMetadata(items []string) map[string]string {
+	res := make(map[string]string)
+	for _, item := range items {
+		if kv := strings.SplitN(item, "=", 2); len(kv) == 2 {
+			res[kv[0]] = kv[1]
+		}
+	}
+	return res
+}
@@ -68,6 +68,26 @@ func (p *ImageParams) Within(ll LatLon) bool {
 	return x >= 0 && y >= 0 && x < p.XSize() && y < p.YSize()
 }
 
+// Subset returns the image parameters of a pixel window clipped to the image, the transform shifted to its origin.
+func (p *ImageParams) Subset(box Box) *ImageParams {
+	box = p.Clip(box)
+	res := p.copy()
+	res.xSize = box[2]
+	res.ySize = box[3]
+	res.transform[0] += float64(box[0]) * p.transform[1]
+	res.transform[3] += float64(box[1]) * p.transform[5]
+	return res
+}
+
+// Clip returns the part of a pixel window within the image.
+func (p *ImageParams) Clip(box Box) Box {
+	x0 := math.Max(0, float64(box[0]))
+	y0 := math.Max(0, float64(box[1]))
+	x1 := math.Min(float64(p.xSize), float64(box[0]+box[2]))
+	y1 := math.Min(float64(p.ySize), float64(box[1]+box[3]))
+	return Box{int(x0), int(y0), int(math.Max(0, x1-x0)), int(math.Max(0, y1-y0))}
+}
+
 func ImageParamsBuilder(xSize, ySize int) *imageParamsBuilder {
 	ip := &ImageParams{
 		xSize:      xSize,
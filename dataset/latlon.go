@@ -145,3 +145,33 @@ func (at AffineTransform) LatLon2Pixels(ll LatLon) (int, int) {
 	ll, _ = ll.Degrees2Sin()
 	return at.LatLonSin2Pixels(ll)
 }
+
+// PixelsDegrees transforms the centres of image pixels from the projection of the image into lat/lon in
+// degrees (WGS84).
+func (p *ImageParams) PixelsDegrees(pixels [][2]int) ([]LatLon, error) {
+	at := p.Transform()
+	lat := make([]float64, len(pixels))
+	lon := make([]float64, len(pixels))
+	z := make([]float64, len(pixels))
+	for i, px := range pixels {
+		lat[i] = (float64(px[1])+0.5)*at[5] + at[3]
+		lon[i] = (float64(px[0])+0.5)*at[1] + at[0]
+	}
+	from := gdal.CreateSpatialReference(p.Projection())
+	defer from.Destroy()
+	to, err := LatLon{}.CSRFromESPG(4326)
+	if err != nil {
+		return nil, err
+	}
+	defer to.Destroy()
+	t := gdal.CreateCoordinateTransform(from, to)
+	defer t.Destroy()
+	if len(pixels) > 0 && !t.Transform(len(pixels), lon, lat, z) {
+		return nil, errors.New("transformation failed")
+	}
+	res := make([]LatLon, len(pixels))
+	for i := range res {
+		res[i] = LatLon{lat[i], lon[i]}
+	}
+	return res, nil
+}
@@ -2,13 +2,23 @@ package dataset
 
 import (
 	"encoding/json"
-	"io/ioutil"
 	"math"
-	"os"
-	"path"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/nordicsense/landsat/io"
+	"github.com/nordicsense/landsat/product"
+)
+
+// Dataset-level metadata keys under which the image metadata is stored in converted images.
+const (
+	DateKey         = "DATE"
+	SunElevationKey = "SUN_ELEVATION"
+	SunAzimuthKey   = "SUN_AZIMUTH"
+	SpacecraftKey   = "SPACECRAFT_ID"
+
+	dateFormat = "2006-01-02"
 )
 
 type ImageMetadata struct {
@@ -26,29 +36,29 @@ type BandMetadata struct {
 	RadMin, RadMax      float64
 }
 
+// ParseMetadata reads the MTL metadata of the product in the directory or tar archive.
 func ParseMetadata(root, prefix string) (ImageMetadata, error) {
 	var (
 		err   error
-		jf    *os.File
 		bytes []byte
 		data  map[string]interface{}
 	)
 
-	fi := path.Join(root, strings.Replace(prefix, "_SR", "", 1)+"_MTL.json")
-	if jf, err = os.Open(fi); err == nil {
-		defer func() { _ = jf.Close() }()
-		if bytes, err = ioutil.ReadAll(jf); err == nil {
-			err = json.Unmarshal(bytes, &data)
-		}
-	}
 	im := ImageMetadata{Aux: make(map[string]string), Bands: make(map[int]BandMetadata)}
+	id, err := product.ParseID(prefix)
+	if err != nil {
+		return im, err
+	}
+	if bytes, err = io.ReadFile(root, id.String()+"_MTL.json"); err == nil {
+		err = json.Unmarshal(bytes, &data)
+	}
 	if err != nil {
 		return im, err
 	}
 	data = (data["LANDSAT_METADATA_FILE"]).(map[string]interface{})
 
 	image := (data["IMAGE_ATTRIBUTES"]).(map[string]interface{})
-	if im.Date, err = time.Parse("2006-01-02", (image["DATE_ACQUIRED"]).(string)); err != nil {
+	if im.Date, err = time.Parse(dateFormat, (image["DATE_ACQUIRED"]).(string)); err != nil {
 		return im, err
 	}
 	if im.SunElevation, err = strconv.ParseFloat((image["SUN_ELEVATION"]).(string), 64); err != nil {
@@ -91,3 +101,38 @@ func ParseMetadata(root, prefix string) (ImageMetadata, error) {
 	}
 	return im, nil
 }
+
+// DatasetParams converts the image metadata into dataset-level metadata.
+func (im ImageMetadata) DatasetParams() *DatasetParams {
+	format := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 6, 64)
+	}
+	dpb := DatasetParamsBuilder().
+		Metadata(DateKey, im.Date.Format(dateFormat)).
+		Metadata(SunElevationKey, format(im.SunElevation)).
+		Metadata(SunAzimuthKey, format(im.SunAzimuth))
+	for k, v := range im.Aux {
+		dpb = dpb.Metadata(k, v)
+	}
+	return dpb.Build()
+}
+
+// Date returns the acquisition date stored in the dataset metadata.
+func (p *DatasetParams) Date() (time.Time, bool) {
+	v, ok := p.MetadataItem(DateKey)
+	if !ok {
+		return time.Time{}, false
+	}
+	date, err := time.Parse(dateFormat, v)
+	return date, err == nil
+}
+
+// LandsatId returns the Landsat series number, e.g. 8 for LANDSAT_8, stored in the dataset metadata.
+func (p *DatasetParams) LandsatId() (int, bool) {
+	v, ok := p.MetadataItem(SpacecraftKey)
+	if !ok || !strings.HasPrefix(v, "LANDSAT_") {
+		return 0, false
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(v, "LANDSAT_"))
+	return id, err == nil
+}
@@ -18,8 +18,9 @@ func OpenMultiBand(fileName string) (MultiBandReader, error) {
 	}
 	var bands []*uniBand
 	var ip *ImageParams
+	dp := readDatasetParams(ds)
 	for i := 1; i <= nb; i++ {
-		band, err := openSingleBand(ds, i)
+		band, err := openSingleBand(ds, i, dp)
 		if err != nil {
 			ds.Close()
 			return nil, err
@@ -29,7 +30,7 @@ func OpenMultiBand(fileName string) (MultiBandReader, error) {
 			ip = band.ImageParams()
 		}
 	}
-	return &multiBand{Dataset: ds, ip: ip, bands: bands}, nil
+	return &multiBand{Dataset: ds, ip: ip, dp: dp, bands: bands}, nil
 }
 
 func NewMultiBand(fileName string, driver Driver, n int, ip *ImageParams, options ...string) (MultiBandWriter, error) {
@@ -50,15 +51,17 @@ func NewMultiBand(fileName string, driver Driver, n int, ip *ImageParams, option
 		}
 	}
 	var bands []*uniBand
+	dp := DatasetParamsBuilder().Build()
 	for i := 1; i <= ds.RasterCount(); i++ {
-		bands = append(bands, &uniBand{Dataset: ds, band: i, ip: ip, rp: &RasterParams{}})
+		bands = append(bands, &uniBand{Dataset: ds, band: i, ip: ip, rp: &RasterParams{}, dp: dp})
 	}
-	return &multiBand{Dataset: ds, ip: ip, bands: bands}, nil
+	return &multiBand{Dataset: ds, ip: ip, dp: dp, bands: bands}, nil
 }
 
 type multiBand struct {
 	gdal.Dataset
-	ip *ImageParams
+	ip    *ImageParams
+	dp    *DatasetParams
 	bands []*uniBand
 }
 
@@ -66,6 +69,18 @@ func (mb *multiBand) ImageParams() *ImageParams {
 	return mb.ip
 }
 
+func (mb *multiBand) DatasetParams() *DatasetParams {
+	return mb.dp
+}
+
+func (mb *multiBand) SetDatasetParams(dp *DatasetParams) error {
+	mb.dp = dp
+	for _, band := range mb.bands {
+		band.dp = dp
+	}
+	return writeDatasetParams(mb.Dataset, dp)
+}
+
 func (mb *multiBand) Bands() int {
 	return mb.RasterCount()
 }
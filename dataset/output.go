@@ -0,0 +1,176 @@
+package dataset
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/nordicsense/gdal"
+)
+
+const (
+	cogDriver   = "COG"
+	cogSuffix   = ".cog.tmp"
+	blockSize   = 512
+	minOverview = 256
+)
+
+// OutputOptions are the common options controlling how products are written: compression profile, tiling,
+// overviews and Cloud-Optimized GeoTIFF layout.
+type OutputOptions struct {
+	// Compression is one of the profiles NONE, LZW, DEFLATE or ZSTD.
+	Compression string
+	// Tiled writes internal tiles instead of strips, implied by COG.
+	Tiled bool
+	// Overviews builds internal overviews, implied by COG.
+	Overviews bool
+	// Resampling is the overview resampling, MODE for class maps and AVERAGE for reflectance.
+	Resampling string
+	// COG rewrites the product into a Cloud-Optimized GeoTIFF once complete.
+	COG bool
+	// Extra are verbatim GDAL creation options, e.g. compress=deflate zlevel=6 predictor=3, taking precedence.
+	Extra []string
+}
+
+// ClassMapOutput returns the default output options for classification and change maps.
+func ClassMapOutput() OutputOptions {
+	return OutputOptions{Compression: "LZW", Resampling: "MODE"}
+}
+
+// ReflectanceOutput returns the default output options for multi-band reflectance images.
+func ReflectanceOutput() OutputOptions {
+	return OutputOptions{Compression: "NONE", Resampling: "AVERAGE"}
+}
+
+// CreationOptions returns the GTiff creation options for writing a product of the given data type.
+func (o OutputOptions) CreationOptions(dt gdal.DataType) []string {
+	res := append([]string{}, o.Extra...)
+	compression := strings.ToUpper(o.Compression)
+	if compression != "" && compression != "NONE" {
+		res = append(res, "COMPRESS="+compression)
+		if dt == gdal.Float32 || dt == gdal.Float64 {
+			res = append(res, "PREDICTOR=3")
+		} else {
+			res = append(res, "PREDICTOR=2")
+		}
+		switch compression {
+		case "DEFLATE":
+			res = append(res, "ZLEVEL=6")
+		case "ZSTD":
+			res = append(res, "ZSTD_LEVEL=9")
+		}
+	}
+	if o.Tiled || o.COG {
+		size := strconv.Itoa(blockSize)
+		res = append(res, "TILED=YES", "BLOCKXSIZE="+size, "BLOCKYSIZE="+size)
+	}
+	res = append(res, "BIGTIFF=IF_SAFER")
+	return res
+}
+
+func (o OutputOptions) cogOptions() []string {
+	var res []string
+	compression := strings.ToUpper(o.Compression)
+	if compression != "" && compression != "NONE" {
+		res = append(res, "COMPRESS="+compression, "PREDICTOR=YES")
+		switch compression {
+		case "DEFLATE":
+			res = append(res, "LEVEL=6")
+		case "ZSTD":
+			res = append(res, "LEVEL=9")
+		}
+	}
+	res = append(res, "BLOCKSIZE="+strconv.Itoa(blockSize), "OVERVIEWS=AUTO", "BIGTIFF=IF_SAFER")
+	if o.Resampling != "" {
+		res = append(res, "OVERVIEW_RESAMPLING="+o.Resampling)
+	}
+	return res
+}
+
+// Finalize completes a product written with CreationOptions: it builds overviews or rewrites the product into
+// a Cloud-Optimized GeoTIFF, which also carries over the metadata already written.
+func Finalize(fileName string, o OutputOptions) error {
+	switch {
+	case o.COG:
+		return toCOG(fileName, o)
+	case o.Overviews:
+		return buildOverviews(fileName, o.Resampling)
+	}
+	return nil
+}
+
+func buildOverviews(fileName, resampling string) error {
+	ds, err := gdal.Open(fileName, gdal.Update)
+	if err != nil {
+		return err
+	}
+	defer ds.Close()
+	levels := overviewLevels(ds.RasterXSize(), ds.RasterYSize())
+	if len(levels) == 0 {
+		return nil
+	}
+	var bands []int
+	for i := 1; i <= ds.RasterCount(); i++ {
+		bands = append(bands, i)
+	}
+	if resampling == "" {
+		resampling = "NEAREST"
+	}
+	return ds.BuildOverviews(resampling, len(levels), levels, len(bands), bands, gdal.DummyProgress, nil)
+}
+
+func toCOG(fileName string, o OutputOptions) error {
+	driver, err := gdal.GetDriverByName(cogDriver)
+	if err != nil {
+		return err
+	}
+	src, err := gdal.Open(fileName, gdal.ReadOnly)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmpName := fileName + cogSuffix
+	// the bindings signal a failed copy by a null dataset only
+	dst := driver.CreateCopy(tmpName, src, 0, o.cogOptions(), nil, nil)
+	if dst == (gdal.Dataset{}) {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to write Cloud-Optimized GeoTIFF for %s", fileName)
+	}
+	dst.Close()
+	if err = checkCopy(src, tmpName); err != nil {
+		_ = os.Remove(tmpName)
+		return fmt.Errorf("failed to write Cloud-Optimized GeoTIFF for %s: %v", fileName, err)
+	}
+	if err = os.Rename(tmpName, fileName); err != nil {
+		return err
+	}
+	// auxiliary metadata such as the attribute table is rewritten next to the temporary file
+	if _, err = os.Stat(tmpName + ".aux.xml"); err == nil {
+		return os.Rename(tmpName+".aux.xml", fileName+".aux.xml")
+	}
+	return nil
+}
+
+// checkCopy reopens a copy of the dataset and compares its size and bands with the source.
+func checkCopy(src gdal.Dataset, fileName string) error {
+	dst, err := gdal.Open(fileName, gdal.ReadOnly)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	if dst.RasterXSize() != src.RasterXSize() || dst.RasterYSize() != src.RasterYSize() || dst.RasterCount() != src.RasterCount() {
+		return fmt.Errorf("copy of %dx%dx%d differs from the source of %dx%dx%d", dst.RasterXSize(), dst.RasterYSize(),
+			dst.RasterCount(), src.RasterXSize(), src.RasterYSize(), src.RasterCount())
+	}
+	return nil
+}
+
+// overviewLevels returns power of 2 decimation factors down to the size of a single block.
+func overviewLevels(nx, ny int) []int {
+	var res []int
+	for level := 2; nx/level >= minOverview || ny/level >= minOverview; level *= 2 {
+		res = append(res, level)
+	}
+	return res
+}
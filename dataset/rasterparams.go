@@ -1,20 +1,33 @@
 package dataset
 
+import "github.com/nordicsense/gdal"
+
 type RasterParams struct {
-	offset     float64
-	scale      float64
-	metadata   map[string]string
+	offset      float64
+	scale       float64
+	metadata    map[string]string
+	description string
+	colorInterp gdal.ColorInterp
+	categories  map[int]Category
 }
 
 func (p *RasterParams) copy() *RasterParams {
 	res := &RasterParams{
-		offset:     p.offset,
-		scale:      p.scale,
-		metadata:   make(map[string]string),
+		offset:      p.offset,
+		scale:       p.scale,
+		metadata:    make(map[string]string),
+		description: p.description,
+		colorInterp: p.colorInterp,
 	}
 	for k, v := range p.metadata {
 		res.metadata[k] = v
 	}
+	if p.categories != nil {
+		res.categories = make(map[int]Category)
+		for k, v := range p.categories {
+			res.categories[k] = v
+		}
+	}
 	return res
 }
 
@@ -34,11 +47,27 @@ func (p *RasterParams) Metadata() map[string]string {
 	return p.metadata // TODO: copy or protect
 }
 
+// Description returns the band description, e.g. the spectral band name.
+func (p *RasterParams) Description() string {
+	return p.description
+}
+
+// ColorInterp returns the colour interpretation of the band.
+func (p *RasterParams) ColorInterp() gdal.ColorInterp {
+	return p.colorInterp
+}
+
+// Categories returns the legend of a categorical band by raster value, nil for continuous bands.
+func (p *RasterParams) Categories() map[int]Category {
+	return p.categories
+}
+
 func RasterParamsBuilder() *rasterParamsBuilder {
 	ip := &RasterParams{
-		offset:     0.0,
-		scale:      1.0,
-		metadata:   make(map[string]string),
+		offset:      0.0,
+		scale:       1.0,
+		metadata:    make(map[string]string),
+		colorInterp: gdal.CI_Undefined,
 	}
 	return &rasterParamsBuilder{RasterParams: ip}
 }
@@ -62,6 +91,25 @@ func (ipb *rasterParamsBuilder) Metadata(key, value string) *rasterParamsBuilder
 	return ipb
 }
 
+func (ipb *rasterParamsBuilder) Description(description string) *rasterParamsBuilder {
+	ipb.description = description
+	return ipb
+}
+
+func (ipb *rasterParamsBuilder) ColorInterp(colorInterp gdal.ColorInterp) *rasterParamsBuilder {
+	ipb.colorInterp = colorInterp
+	return ipb
+}
+
+// Categories sets the legend of a categorical band replacing any existing one.
+func (ipb *rasterParamsBuilder) Categories(categories map[int]Category) *rasterParamsBuilder {
+	ipb.categories = make(map[int]Category)
+	for k, v := range categories {
+		ipb.categories[k] = v
+	}
+	return ipb
+}
+
 func (ipb *rasterParamsBuilder) Build() *RasterParams {
 	return ipb.RasterParams.copy()
 }
@@ -0,0 +1,136 @@
+package dataset
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/nordicsense/gdal"
+)
+
+// Pixel lists the buffer types supported by typed block reads and writes.
+type Pixel interface {
+	uint8 | int16 | uint16 | int32 | float32 | float64
+}
+
+type bandAccessor interface {
+	rasterBand() gdal.RasterBand
+}
+
+// ReadTyped reads raw band values of a block into a caller-provided buffer of at least box[2]*box[3] elements.
+// No scaling or nodata conversion is applied, see ReadScaled, and GDAL converts the values to the buffer type.
+func ReadTyped[T Pixel](r UniBandReader, x, y int, box Box, buffer []T) error {
+	return typedIO(gdal.Read, r, x, y, box, buffer)
+}
+
+// WriteTyped writes raw band values of a block from a buffer of at least box[2]*box[3] elements.
+// No scaling or nodata conversion is applied, see WriteScaled, and GDAL converts the values to the band type.
+func WriteTyped[T Pixel](w UniBandWriter, x, y int, box Box, buffer []T) error {
+	return typedIO(gdal.Write, w, x, y, box, buffer)
+}
+
+func typedIO[T Pixel](flag gdal.RWFlag, band interface{}, x, y int, box Box, buffer []T) error {
+	ba, ok := band.(bandAccessor)
+	if !ok {
+		return fmt.Errorf("unsupported band implementation %T", band)
+	}
+	if len(buffer) < box[2]*box[3] {
+		return fmt.Errorf("buffer of %d elements is too small for block %dx%d", len(buffer), box[2], box[3])
+	}
+	return ba.rasterBand().IO(flag, x+box[0], y+box[1], box[2], box[3], buffer, box[2], box[3], 0, 0)
+}
+
+// Float lists the buffer types of scaled block reads and writes.
+type Float interface {
+	float32 | float64
+}
+
+// Scaling maps raw band values to scaled ones as raw*Scale+Offset, and the nodata value, if any, to NaN.
+type Scaling struct {
+	Scale, Offset float64
+	NoData        float64
+	HasNoData     bool
+}
+
+// ScalingOf returns the scaling of a band given by its image and raster parameters.
+func ScalingOf(ip *ImageParams, rp *RasterParams) Scaling {
+	nan, hasnan := ip.NaN()
+	return Scaling{Scale: rp.Scale(), Offset: rp.Offset(), NoData: nan, HasNoData: hasnan && !math.IsNaN(nan)}
+}
+
+// ReadScaled reads a block into a caller-provided buffer of at least box[2]*box[3] elements as ReadTyped does,
+// converting the raw values in place by the scaling of the band, nodata to NaN.
+func ReadScaled[F Float](r UniBandReader, x, y int, box Box, buffer []F) error {
+	if err := ReadTyped(r, x, y, box, buffer); err != nil {
+		return err
+	}
+	scale(ScalingOf(r.ImageParams(), r.RasterParams()), buffer[:box[2]*box[3]])
+	return nil
+}
+
+// WriteScaled writes a block of scaled values from a buffer of at least box[2]*box[3] elements, converting them
+// back to raw ones of the band type, NaN to nodata. Integer values are rounded; NaN values fail on integer bands
+// without nodata.
+func WriteScaled[F Float](w UniBandWriter, x, y int, box Box, buffer []F) error {
+	if len(buffer) < box[2]*box[3] {
+		return fmt.Errorf("buffer of %d elements is too small for block %dx%d", len(buffer), box[2], box[3])
+	}
+	buffer = buffer[:box[2]*box[3]]
+	s := ScalingOf(w.ImageParams(), w.RasterParams())
+	switch w.ImageParams().DataType() {
+	case gdal.Byte:
+		return writeUnscaled[uint8](w, x, y, box, s, buffer)
+	case gdal.Int16:
+		return writeUnscaled[int16](w, x, y, box, s, buffer)
+	case gdal.UInt16:
+		return writeUnscaled[uint16](w, x, y, box, s, buffer)
+	case gdal.Int32:
+		return writeUnscaled[int32](w, x, y, box, s, buffer)
+	case gdal.Float32:
+		return writeUnscaled[float32](w, x, y, box, s, buffer)
+	default: // treat as float64
+		return writeUnscaled[float64](w, x, y, box, s, buffer)
+	}
+}
+
+func writeUnscaled[T Pixel, F Float](w UniBandWriter, x, y int, box Box, s Scaling, buffer []F) error {
+	data, err := unscale[T](s, buffer)
+	if err != nil {
+		return err
+	}
+	return typedIO(gdal.Write, w, x, y, box, data)
+}
+
+// scale converts raw values in place to scaled ones, nodata to NaN.
+func scale[F Float](s Scaling, buffer []F) {
+	for i, v := range buffer {
+		if s.HasNoData && v == F(s.NoData) {
+			buffer[i] = F(math.NaN())
+		} else {
+			buffer[i] = F(float64(v)*s.Scale + s.Offset)
+		}
+	}
+}
+
+// unscale converts scaled values to raw ones of the band type, NaN to nodata, rounding for integer types. NaN
+// values without nodata fail for integer types, as their conversion is undefined, and stay NaN for float types.
+func unscale[T Pixel, F Float](s Scaling, buffer []F) ([]T, error) {
+	var zero T
+	_, isFloat32 := interface{}(zero).(float32)
+	_, isFloat64 := interface{}(zero).(float64)
+	round := !isFloat32 && !isFloat64
+	res := make([]T, len(buffer))
+	for i, v := range buffer {
+		vv := float64(v)
+		switch {
+		case math.IsNaN(vv) && s.HasNoData:
+			res[i] = T(s.NoData)
+		case math.IsNaN(vv) && round:
+			return nil, fmt.Errorf("NaN at %d cannot be written to an integer band without nodata", i)
+		case round:
+			res[i] = T(math.Round((vv - s.Offset) / s.Scale))
+		default:
+			res[i] = T((vv - s.Offset) / s.Scale)
+		}
+	}
+	return res, nil
+}
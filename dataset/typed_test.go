@@ -0,0 +1,41 @@
+package dataset
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestScale(t *testing.T) {
+	s := Scaling{Scale: 0.5, Offset: 1, NoData: 0, HasNoData: true}
+	buffer := []float32{0, 2, 4}
+	scale(s, buffer)
+	if !math.IsNaN(float64(buffer[0])) || buffer[1] != 2 || buffer[2] != 3 {
+		t.Errorf("expected NaN, 2 and 3, found %v", buffer)
+	}
+	raw, err := unscale[uint8](s, buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []uint8{0, 2, 4}; !reflect.DeepEqual(raw, expected) {
+		t.Errorf("expected %v, found %v", expected, raw)
+	}
+}
+
+func TestUnscaleNaN(t *testing.T) {
+	s := Scaling{Scale: 1}
+	if _, err := unscale[int16](s, []float64{1, math.NaN()}); err == nil {
+		t.Error("expected NaN on an integer band without nodata to fail")
+	}
+	raw, err := unscale[float32](s, []float64{1.5, math.NaN()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw[0] != 1.5 || !math.IsNaN(float64(raw[1])) {
+		t.Errorf("expected 1.5 and NaN, found %v", raw)
+	}
+	s.NoData, s.HasNoData = -9999, true
+	if raw, err := unscale[int16](s, []float64{2.6, math.NaN()}); err != nil || raw[0] != 3 || raw[1] != -9999 {
+		t.Errorf("expected 3 and nodata, found %v: %v", raw, err)
+	}
+}
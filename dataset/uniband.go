@@ -15,10 +15,10 @@ func OpenUniBand(fileName string) (UniBandReader, error) {
 		ds.Close()
 		return nil, fmt.Errorf("no raster bands found")
 	}
-	return openSingleBand(ds, 1)
+	return openSingleBand(ds, 1, readDatasetParams(ds))
 }
 
-func openSingleBand(ds gdal.Dataset, band int) (*uniBand, error) {
+func openSingleBand(ds gdal.Dataset, band int, dp *DatasetParams) (*uniBand, error) {
 	rb := ds.RasterBand(band)
 	ipb := ImageParamsBuilder(ds.RasterXSize(), ds.RasterYSize()).
 		DataType(rb.RasterDataType()).
@@ -34,10 +34,18 @@ func openSingleBand(ds gdal.Dataset, band int) (*uniBand, error) {
 	if offset, ok := rb.GetOffset(); ok {
 		rpb = rpb.Offset(offset)
 	}
-	for _, k := range rb.Metadata(domain) {
-		rpb = rpb.Metadata(k, rb.MetadataItem(k, domain))
+	for k, v := range parseMetadata(rb.Metadata(domain)) {
+		if k == bandDescriptionKey {
+			rpb = rpb.Description(v)
+		} else {
+			rpb = rpb.Metadata(k, v)
+		}
 	}
-	return &uniBand{Dataset: ds, band: band, ip: ipb.Build(), rp: rpb.Build()}, nil
+	rpb = rpb.ColorInterp(rb.ColorInterp())
+	if categories := readCategories(rb); categories != nil {
+		rpb = rpb.Categories(categories)
+	}
+	return &uniBand{Dataset: ds, band: band, ip: ipb.Build(), rp: rpb.Build(), dp: dp}, nil
 }
 
 func NewUniBand(fileName string, driver Driver, ip *ImageParams, rp *RasterParams, options ...string) (UniBandWriter, error) {
@@ -52,7 +60,7 @@ func NewUniBand(fileName string, driver Driver, ip *ImageParams, rp *RasterParam
 	if err = ds.SetProjection(ip.Projection()); err != nil {
 		return nil, err
 	}
-	ub := &uniBand{Dataset: ds, band: 1, ip: ip}
+	ub := &uniBand{Dataset: ds, band: 1, ip: ip, dp: DatasetParamsBuilder().Build()}
 	return ub, ub.SetRasterParams(rp)
 }
 
@@ -61,6 +69,7 @@ type uniBand struct {
 	band int
 	ip   *ImageParams
 	rp   *RasterParams
+	dp   *DatasetParams
 }
 
 func (ub *uniBand) ImageParams() *ImageParams {
@@ -71,6 +80,15 @@ func (ub *uniBand) RasterParams() *RasterParams {
 	return ub.rp
 }
 
+func (ub *uniBand) DatasetParams() *DatasetParams {
+	return ub.dp
+}
+
+func (ub *uniBand) SetDatasetParams(dp *DatasetParams) error {
+	ub.dp = dp
+	return writeDatasetParams(ub.Dataset, dp)
+}
+
 func (ub *uniBand) SetRasterParams(rp *RasterParams) error {
 	ub.rp = rp
 	var err error
@@ -86,6 +104,20 @@ func (ub *uniBand) SetRasterParams(rp *RasterParams) error {
 			return err
 		}
 	}
+	// the GDAL bindings provide no band description setter, so the description goes into the band metadata
+	if rp.Description() != "" {
+		if err = rb.SetMetadataItem(bandDescriptionKey, rp.Description(), domain); err != nil {
+			return err
+		}
+	}
+	if len(rp.Categories()) > 0 {
+		if err = writeCategories(rb, ub.ImageParams().DataType(), rp.Categories()); err != nil {
+			return err
+		}
+	}
+	if rp.ColorInterp() != gdal.CI_Undefined {
+		err = rb.SetColorInterp(rp.ColorInterp())
+	}
 	return err
 }
 
@@ -108,25 +140,17 @@ func (ub *uniBand) ReadAtLatLon(ll LatLon) (float64, error) {
 }
 
 func (ub *uniBand) ReadBlock(x, y int, box Box) ([]float64, error) {
-	rb := ub.Dataset.RasterBand(ub.band)
 	buffer := make([]float64, box[2]*box[3])
-	err := rb.IO(gdal.Read, x+box[0], y+box[1], box[2], box[3], buffer, box[2], box[3], 0, 0)
-	if err != nil {
+	if err := ub.ReadBlockInto(x, y, box, buffer); err != nil {
 		return nil, err
 	}
-	ip := ub.ImageParams()
-	rp := ub.RasterParams()
-	nan, hasnan := ip.NaN()
-	for i, val := range buffer {
-		if hasnan && buffer[i] == nan {
-			buffer[i] = math.NaN()
-		} else {
-			buffer[i] = val*rp.Scale() + rp.Offset()
-		}
-	}
 	return buffer, nil
 }
 
+func (ub *uniBand) ReadBlockInto(x, y int, box Box, buffer []float64) error {
+	return ReadScaled(ub, x, y, box, buffer)
+}
+
 func (ub *uniBand) Write(x, y int, v float64) error {
 	return ub.WriteBlock(x, y, Box{0, 0, 1, 1}, []float64{v})
 }
@@ -137,35 +161,17 @@ func (ub *uniBand) WriteAtLatLon(ll LatLon, v float64) error {
 }
 
 func (ub *uniBand) WriteBlock(x, y int, box Box, buffer []float64) error {
-	rb := ub.Dataset.RasterBand(ub.band) // Assume 1 band or panic
-	// GDAL can handle any format, but it is more efficient to use specific type as we need to make a copy anyway
-	p := ub.RasterParams()
-	switch ub.ImageParams().DataType() {
-	case gdal.Int32:
-		data := make([]int32, len(buffer))
-		for i, v := range buffer {
-			data[i] = int32((v - p.Offset()) / p.Scale())
-		}
-		return rb.IO(gdal.Write, x+box[0], y+box[1], box[2], box[3], data, box[2], box[3], 0, 0)
-	case gdal.Float32:
-		data := make([]float32, len(buffer))
-		for i, v := range buffer {
-			data[i] = float32((v - p.Offset()) / p.Scale())
-		}
-		return rb.IO(gdal.Write, x+box[0], y+box[1], box[2], box[3], data, box[2], box[3], 0, 0)
-	default: // treat as float64
-		data := make([]float64, len(buffer))
-		for i, v := range buffer {
-			data[i] = (v - p.Offset()) / p.Scale()
-		}
-		return rb.IO(gdal.Write, x+box[0], y+box[1], box[2], box[3], data, box[2], box[3], 0, 0)
-	}
+	return WriteScaled(ub, x, y, box, buffer)
 }
 
 func (ub *uniBand) BreakGlass() gdal.Dataset {
 	return ub.Dataset
 }
 
+func (ub *uniBand) rasterBand() gdal.RasterBand {
+	return ub.Dataset.RasterBand(ub.band)
+}
+
 func (ub *uniBand) Close() {
 	ub.Dataset.Close()
 	ub.ip = nil
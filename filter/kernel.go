@@ -0,0 +1,167 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Kernel defines the odd-sized neighbourhood voting for the class of its centre pixel with per-position
+// weights, zero weights being outside of the kernel.
+type Kernel struct {
+	size    int
+	weights []int
+}
+
+// span is a run of kernel positions dx in [from, to] in row dy, all with a weight of at least its level.
+// The weighted kernel is the sum of its spans over all weight levels, which allows sliding it by adding and
+// removing pixels at the span ends only.
+type span struct {
+	dy, from, to int
+}
+
+// Square returns a square kernel of equal weights.
+func Square(size int) (Kernel, error) {
+	return newKernel(size, func(dx, dy, r int) int { return 1 })
+}
+
+// Circle returns a circular kernel of equal weights covering positions within the radius of size/2 pixels.
+func Circle(size int) (Kernel, error) {
+	return newKernel(size, func(dx, dy, r int) int {
+		if dx*dx+dy*dy <= r*r {
+			return 1
+		}
+		return 0
+	})
+}
+
+// Weights returns a kernel from size*size row-wise weights.
+func Weights(weights []int) (Kernel, error) {
+	size := int(math.Round(math.Sqrt(float64(len(weights)))))
+	if size*size != len(weights) {
+		return Kernel{}, fmt.Errorf("%d weights do not form a square kernel", len(weights))
+	}
+	return newKernel(size, func(dx, dy, r int) int { return weights[(dy+r)*size+dx+r] })
+}
+
+func newKernel(size int, weight func(dx, dy, r int) int) (Kernel, error) {
+	if size < 1 || size%2 == 0 {
+		return Kernel{}, fmt.Errorf("kernel size must be odd and positive, found %d", size)
+	}
+	k := Kernel{size: size, weights: make([]int, size*size)}
+	r := k.Radius()
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			w := weight(dx, dy, r)
+			if w < 0 {
+				return Kernel{}, fmt.Errorf("negative weight %d at (%d,%d)", w, dx, dy)
+			}
+			k.weights[(dy+r)*size+dx+r] = w
+		}
+	}
+	return k, nil
+}
+
+// ParseKernel parses the kernel specification of the filter command: 3x3 and 5x5 for the legacy kernels with
+// heavier centres, square:N or circle:N with an optional :centre=W weight for the centre pixel, or
+// weights:W,W,... for N*N explicit weights.
+func ParseKernel(spec string) (Kernel, error) {
+	switch spec {
+	case "3x3":
+		return Weights([]int{
+			1, 1, 1,
+			1, 3, 1,
+			1, 1, 1,
+		})
+	case "5x5":
+		return Weights([]int{
+			0, 1, 1, 1, 0,
+			1, 1, 3, 1, 1,
+			1, 3, 5, 3, 1,
+			1, 1, 3, 1, 1,
+			0, 1, 1, 1, 0,
+		})
+	}
+	parts := strings.Split(spec, ":")
+	if parts[0] == "weights" && len(parts) == 2 {
+		var weights []int
+		for _, w := range strings.Split(parts[1], ",") {
+			v, err := strconv.Atoi(w)
+			if err != nil {
+				return Kernel{}, err
+			}
+			weights = append(weights, v)
+		}
+		return Weights(weights)
+	}
+	if len(parts) < 2 || len(parts) > 3 {
+		return Kernel{}, fmt.Errorf("unknown kernel %s", spec)
+	}
+	size, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return Kernel{}, err
+	}
+	var k Kernel
+	switch parts[0] {
+	case "square":
+		k, err = Square(size)
+	case "circle":
+		k, err = Circle(size)
+	default:
+		return Kernel{}, fmt.Errorf("unknown kernel shape %s", parts[0])
+	}
+	if err != nil || len(parts) == 2 {
+		return k, err
+	}
+	if !strings.HasPrefix(parts[2], "centre=") {
+		return Kernel{}, fmt.Errorf("unknown kernel parameter %s", parts[2])
+	}
+	w, err := strconv.Atoi(strings.TrimPrefix(parts[2], "centre="))
+	if err != nil {
+		return Kernel{}, err
+	}
+	k.weights[len(k.weights)/2] = w
+	return k, nil
+}
+
+// Size returns the kernel width and height in pixels.
+func (k Kernel) Size() int {
+	return k.size
+}
+
+// Radius returns the number of pixels around the centre.
+func (k Kernel) Radius() int {
+	return k.size / 2
+}
+
+// Weight returns the weight at the offset from the centre.
+func (k Kernel) Weight(dx, dy int) int {
+	r := k.Radius()
+	return k.weights[(dy+r)*k.size+dx+r]
+}
+
+func (k Kernel) spans() []span {
+	var res []span
+	r := k.Radius()
+	for level := 1; ; level++ {
+		found := false
+		for dy := -r; dy <= r; dy++ {
+			from := math.MaxInt
+			for dx := -r; dx <= r+1; dx++ {
+				if dx <= r && k.Weight(dx, dy) >= level {
+					found = true
+					if from == math.MaxInt {
+						from = dx
+					}
+				} else if from != math.MaxInt {
+					res = append(res, span{dy: dy, from: from, to: dx - 1})
+					from = math.MaxInt
+				}
+			}
+		}
+		if !found {
+			return res
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package filter
+
+import (
+	"github.com/nordicsense/landsat/dataset"
+	"github.com/nordicsense/landsat/logging"
+)
+
+// nodata is the class map value not taking part in the vote and kept as is.
+const nodata = 0
+
+// Modal replaces every class of a class map with the weighted majority class within the kernel around it.
+// Nodata pixels neither vote nor get filled and pixels beyond the image edges count as nodata.
+func Modal(inputTiff, outputTiff string, kernel Kernel, skip, verbose bool, output dataset.OutputOptions) error {
+	if skip && dataset.UpToDate(outputTiff, inputTiff) {
+		return nil
+	}
+
+	r, err := dataset.OpenUniBand(inputTiff)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	ip := r.ImageParams().ToBuilder().Build()
+	rp := r.RasterParams().ToBuilder().Build()
+
+	w, err := dataset.NewUniBand(outputTiff, dataset.GTiff, ip, rp, output.CreationOptions(ip.DataType())...)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err = w.SetDatasetParams(r.DatasetParams().Inheritable()); err != nil {
+		return err
+	}
+
+	nx := r.ImageParams().XSize()
+	ny := r.ImageParams().YSize()
+
+	bar := logging.NewProgress("modal", 0, int64(ny))
+	if verbose {
+		bar.Start()
+	}
+
+	// rows of the kernel window padded by the kernel radius on both sides
+	rad := kernel.Radius()
+	window := make([][]uint8, kernel.Size())
+	for i := range window {
+		window[i] = make([]uint8, nx+2*rad)
+	}
+	readRow := func(y int, row []uint8) error {
+		for i := range row {
+			row[i] = nodata
+		}
+		if y < 0 || y >= ny {
+			return nil
+		}
+		return dataset.ReadTyped(r, 0, y, dataset.Box{0, 0, nx, 1}, row[rad:rad+nx])
+	}
+	for i := range window {
+		if err = readRow(i-rad, window[i]); err != nil {
+			return err
+		}
+	}
+
+	f := newModal(kernel)
+	row := make([]uint8, nx)
+	for y := 0; y < ny; y++ {
+		if y > 0 {
+			// rotate the window by one row reusing the buffer of the row leaving it
+			first := window[0]
+			copy(window, window[1:])
+			window[len(window)-1] = first
+			if err = readRow(y+rad, first); err != nil {
+				return err
+			}
+		}
+		f.row(window, row)
+		if err = dataset.WriteTyped(w, 0, y, dataset.Box{0, 0, nx, 1}, row); err != nil {
+			return err
+		}
+		if verbose {
+			bar.Advance(1)
+		}
+	}
+	if verbose {
+		bar.Stop()
+	}
+	return nil
+}
+
+type modal struct {
+	kernel Kernel
+	spans  []span
+	hist   [256]int
+	// maximum class value seen, limits the search for the majority
+	top uint8
+}
+
+func newModal(kernel Kernel) *modal {
+	return &modal{kernel: kernel, spans: kernel.spans()}
+}
+
+// row filters the centre row of the padded window into out sliding the class histogram along the row.
+func (m *modal) row(window [][]uint8, out []uint8) {
+	rad := m.kernel.Radius()
+	m.hist = [256]int{}
+	for _, s := range m.spans {
+		row := window[s.dy+rad]
+		for dx := s.from; dx <= s.to; dx++ {
+			m.add(row[dx+rad])
+		}
+	}
+	centre := window[rad]
+	for x := range out {
+		if x > 0 {
+			for _, s := range m.spans {
+				row := window[s.dy+rad]
+				m.hist[row[x-1+s.from+rad]]--
+				m.add(row[x+s.to+rad])
+			}
+		}
+		out[x] = m.majority(centre[x+rad])
+	}
+}
+
+func (m *modal) add(v uint8) {
+	m.hist[v]++
+	if v > m.top {
+		m.top = v
+	}
+}
+
+// majority returns the most frequent class preferring the current one on ties and keeping nodata.
+func (m *modal) majority(current uint8) uint8 {
+	if current == nodata {
+		return nodata
+	}
+	res, count := current, m.hist[current]
+	for v := 1; v <= int(m.top); v++ {
+		if m.hist[v] > count {
+			res, count = uint8(v), m.hist[v]
+		}
+	}
+	return res
+}
@@ -0,0 +1,83 @@
+package filter
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// bruteForce computes the modal filter pixel by pixel with the same tie breaking as the sliding histogram.
+func bruteForce(img [][]uint8, k Kernel) [][]uint8 {
+	r := k.Radius()
+	res := make([][]uint8, len(img))
+	for y := range img {
+		res[y] = make([]uint8, len(img[y]))
+		for x := range img[y] {
+			current := img[y][x]
+			if current == nodata {
+				continue
+			}
+			var hist [256]int
+			for dy := -r; dy <= r; dy++ {
+				for dx := -r; dx <= r; dx++ {
+					yy, xx := y+dy, x+dx
+					if yy >= 0 && yy < len(img) && xx >= 0 && xx < len(img[y]) {
+						hist[img[yy][xx]] += k.Weight(dx, dy)
+					}
+				}
+			}
+			val, count := current, hist[current]
+			for v := 1; v < 256; v++ {
+				if hist[v] > count {
+					val, count = uint8(v), hist[v]
+				}
+			}
+			res[y][x] = val
+		}
+	}
+	return res
+}
+
+func TestModal_MatchesBruteForce(t *testing.T) {
+	rnd := rand.New(rand.NewSource(42))
+	nx, ny := 23, 17
+	img := make([][]uint8, ny)
+	for y := range img {
+		img[y] = make([]uint8, nx)
+		for x := range img[y] {
+			img[y][x] = uint8(rnd.Intn(5))
+		}
+	}
+	for _, spec := range []string{"3x3", "5x5", "square:3", "circle:7", "circle:5:centre=4", "weights:0,1,0,2,1,2,0,1,0"} {
+		k, err := ParseKernel(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := bruteForce(img, k)
+		rad := k.Radius()
+		f := newModal(k)
+		out := make([]uint8, nx)
+		for y := 0; y < ny; y++ {
+			window := make([][]uint8, k.Size())
+			for i := range window {
+				window[i] = make([]uint8, nx+2*rad)
+				if yy := y + i - rad; yy >= 0 && yy < ny {
+					copy(window[i][rad:], img[yy])
+				}
+			}
+			f.row(window, out)
+			for x := range out {
+				if out[x] != expected[y][x] {
+					t.Fatalf("%s: at (%d,%d) expected %d, found %d", spec, x, y, expected[y][x], out[x])
+				}
+			}
+		}
+	}
+}
+
+func TestParseKernel_Errors(t *testing.T) {
+	for _, spec := range []string{"4x4", "square:4", "circle:x", "hexagon:3", "weights:1,2,3", "square:3:middle=2"} {
+		if _, err := ParseKernel(spec); err == nil {
+			t.Errorf("%s: expected error", spec)
+		}
+	}
+}
@@ -0,0 +1,344 @@
+package filter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nordicsense/landsat/dataset"
+	"github.com/nordicsense/landsat/logging"
+)
+
+// MinSize is the sieve threshold: regions smaller than that are merged into their neighbours. It is given
+// either in pixels or in hectares, e.g. for a minimum mapping unit.
+type MinSize struct {
+	Pixels   int
+	Hectares float64
+}
+
+// ParseSieve parses the sieve specification of the filter command: sieve:N for N pixels or sieve:Aha for A
+// hectares, optionally followed by :4 or :8 for the connectivity (default: 8).
+func ParseSieve(spec string) (MinSize, int, error) {
+	var (
+		res MinSize
+		err error
+	)
+	parts := strings.Split(spec, ":")
+	if parts[0] != "sieve" || len(parts) < 2 || len(parts) > 3 {
+		return res, 0, fmt.Errorf("unknown sieve %s", spec)
+	}
+	if strings.HasSuffix(parts[1], "ha") {
+		res.Hectares, err = strconv.ParseFloat(strings.TrimSuffix(parts[1], "ha"), 64)
+	} else {
+		res.Pixels, err = strconv.Atoi(parts[1])
+	}
+	if err != nil {
+		return res, 0, err
+	}
+	connectivity := 8
+	if len(parts) == 3 {
+		if connectivity, err = strconv.Atoi(parts[2]); err != nil {
+			return res, 0, err
+		}
+	}
+	if connectivity != 4 && connectivity != 8 {
+		return res, 0, fmt.Errorf("connectivity must be 4 or 8, found %d", connectivity)
+	}
+	return res, connectivity, nil
+}
+
+func (m MinSize) pixels(at dataset.AffineTransform) int {
+	if m.Hectares > 0 {
+		return int(math.Ceil(m.Hectares * 10000. / math.Abs(at[1]*at[5])))
+	}
+	return m.Pixels
+}
+
+// Sieve merges connected regions of equal class smaller than the minimum size into the neighbouring class
+// sharing the longest border with them. Nodata regions are neither merged nor merged into.
+//
+// The class map is streamed row by row three times: to label regions, to count their borders and to write the
+// result. Only a few rows are kept, but the region roots take 8 bytes per provisional label, which grows with the
+// number of regions and on speckled maps up to the number of pixels. Border counts take 4 bytes per class present
+// and region below the threshold.
+func Sieve(inputTiff, outputTiff string, minSize MinSize, connectivity int, skip, verbose bool, output dataset.OutputOptions) error {
+	if skip && dataset.UpToDate(outputTiff, inputTiff) {
+		return nil
+	}
+
+	r, err := dataset.OpenUniBand(inputTiff)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	ip := r.ImageParams().ToBuilder().Build()
+	rp := r.RasterParams().ToBuilder().Build()
+
+	w, err := dataset.NewUniBand(outputTiff, dataset.GTiff, ip, rp, output.CreationOptions(ip.DataType())...)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err = w.SetDatasetParams(r.DatasetParams().Inheritable()); err != nil {
+		return err
+	}
+
+	nx := ip.XSize()
+	ny := ip.YSize()
+	threshold := minSize.pixels(ip.Transform())
+
+	bar := logging.NewProgress("sieve", 0, int64(3*ny))
+	if verbose {
+		bar.Start()
+	}
+
+	s := newSieve(nx, connectivity == 8)
+	scan := func(process func(y int, row []uint8, roots []int32) error) error {
+		s.reset()
+		for y := 0; y < ny; y++ {
+			row := s.row()
+			if err := dataset.ReadTyped(r, 0, y, dataset.Box{0, 0, nx, 1}, row); err != nil {
+				return err
+			}
+			if err := process(y, row, s.label()); err != nil {
+				return err
+			}
+			if verbose {
+				bar.Advance(1)
+			}
+		}
+		return nil
+	}
+
+	// pass 1: label regions and union labels meeting in later rows
+	if err = scan(func(int, []uint8, []int32) error { return nil }); err != nil {
+		return err
+	}
+	s.freeze(threshold)
+	// pass 2: count borders of small regions with neighbouring classes
+	if err = scan(func(int, []uint8, []int32) error {
+		s.countBorders()
+		return nil
+	}); err != nil {
+		return err
+	}
+	s.decide()
+	// pass 3: write the merged classes, the rows read are still needed for labelling
+	out := make([]uint8, nx)
+	err = scan(func(y int, row []uint8, roots []int32) error {
+		copy(out, row)
+		for x, root := range roots {
+			if target, ok := s.target(root); ok {
+				out[x] = target
+			}
+		}
+		return dataset.WriteTyped(w, 0, y, dataset.Box{0, 0, nx, 1}, out)
+	})
+	if verbose {
+		bar.Stop()
+	}
+	return err
+}
+
+type sieve struct {
+	conn8 bool
+	// union-find over provisional labels, which are reproduced identically by every scan
+	parent []int32
+	size   []int32
+	frozen bool
+	next   int32
+	// classes present in the map, indexed densely in the order of their values
+	present [256]bool
+	classes []uint8
+	index   [256]int
+	// slot indexes regions below the threshold by their root, -1 for all other labels; borders holds the border
+	// length of every such region by neighbouring class and targets the class it is merged into, -1 for none
+	slot    []int32
+	borders []int32
+	targets []int16
+
+	prevRow, curRow       []uint8
+	prevLabels, curLabels []int32
+}
+
+func newSieve(nx int, conn8 bool) *sieve {
+	return &sieve{
+		conn8:      conn8,
+		prevRow:    make([]uint8, nx),
+		curRow:     make([]uint8, nx),
+		prevLabels: make([]int32, nx),
+		curLabels:  make([]int32, nx),
+	}
+}
+
+// reset starts a new scan from the first row.
+func (s *sieve) reset() {
+	s.next = 0
+	for x := range s.curLabels {
+		s.curLabels[x] = -1
+	}
+}
+
+// row returns the buffer to read the next row into.
+func (s *sieve) row() []uint8 {
+	s.prevRow, s.curRow = s.curRow, s.prevRow
+	s.prevLabels, s.curLabels = s.curLabels, s.prevLabels
+	return s.curRow
+}
+
+// label assigns region labels to the current row: during the first scan these are provisional labels with
+// unions recorded, during later scans the region roots.
+func (s *sieve) label() []int32 {
+	row, prev := s.curRow, s.prevRow
+	for x, v := range row {
+		label := int32(-1)
+		if v != nodata {
+			join := func(other int32) {
+				if label < 0 {
+					label = other
+				} else if !s.frozen {
+					s.union(label, other)
+				}
+			}
+			if x > 0 && row[x-1] == v {
+				join(s.curLabels[x-1])
+			}
+			if s.prevLabels[x] >= 0 && prev[x] == v {
+				join(s.prevLabels[x])
+			}
+			if s.conn8 {
+				if x > 0 && s.prevLabels[x-1] >= 0 && prev[x-1] == v {
+					join(s.prevLabels[x-1])
+				}
+				if x < len(row)-1 && s.prevLabels[x+1] >= 0 && prev[x+1] == v {
+					join(s.prevLabels[x+1])
+				}
+			}
+			if label < 0 {
+				label = s.next
+				s.next++
+				if s.frozen {
+					label = s.find(label)
+				} else {
+					s.parent = append(s.parent, label)
+					s.size = append(s.size, 0)
+				}
+			}
+			if !s.frozen {
+				s.size[label]++
+				s.present[v] = true
+			}
+		}
+		s.curLabels[x] = label
+	}
+	return s.curLabels
+}
+
+func (s *sieve) find(label int32) int32 {
+	for s.parent[label] != label {
+		s.parent[label] = s.parent[s.parent[label]]
+		label = s.parent[label]
+	}
+	return label
+}
+
+func (s *sieve) union(a, b int32) {
+	ra, rb := s.find(a), s.find(b)
+	if ra < rb {
+		s.parent[rb] = ra
+	} else if rb < ra {
+		s.parent[ra] = rb
+	}
+}
+
+// freeze completes labelling: sizes are accumulated at the roots and regions below the threshold selected.
+func (s *sieve) freeze(threshold int) {
+	s.frozen = true
+	// only roots receive counts of other labels, so the count of every other label is still its own
+	for label := range s.parent {
+		if root := s.find(int32(label)); root != int32(label) {
+			s.size[root] += s.size[label]
+		}
+	}
+	s.classes = s.classes[:0]
+	for v, ok := range s.present {
+		if ok {
+			s.index[v] = len(s.classes)
+			s.classes = append(s.classes, uint8(v))
+		}
+	}
+	// the sizes are no longer needed, their storage is reused for the slots
+	s.slot, s.size = s.size, nil
+	n := int32(0)
+	for label, parent := range s.parent {
+		if parent == int32(label) && int(s.slot[label]) < threshold {
+			s.slot[label] = n
+			n++
+		} else {
+			s.slot[label] = -1
+		}
+	}
+	s.borders = make([]int32, int(n)*len(s.classes))
+	s.targets = make([]int16, n)
+}
+
+// countBorders counts every pair of neighbouring pixels of different regions once, from the later pixel.
+func (s *sieve) countBorders() {
+	row, prev := s.curRow, s.prevRow
+	count := func(label int32, v uint8, otherLabel int32, other uint8) {
+		if label < 0 || otherLabel < 0 || label == otherLabel {
+			return
+		}
+		if slot := s.slot[label]; slot >= 0 {
+			s.borders[int(slot)*len(s.classes)+s.index[other]]++
+		}
+		if slot := s.slot[otherLabel]; slot >= 0 {
+			s.borders[int(slot)*len(s.classes)+s.index[v]]++
+		}
+	}
+	for x, v := range row {
+		label := s.curLabels[x]
+		if x > 0 {
+			count(label, v, s.curLabels[x-1], row[x-1])
+		}
+		count(label, v, s.prevLabels[x], prev[x])
+		if s.conn8 {
+			if x > 0 {
+				count(label, v, s.prevLabels[x-1], prev[x-1])
+			}
+			if x < len(row)-1 {
+				count(label, v, s.prevLabels[x+1], prev[x+1])
+			}
+		}
+	}
+}
+
+// decide selects the class sharing the longest border with every small region, the lower class on ties.
+func (s *sieve) decide() {
+	nc := len(s.classes)
+	for slot := range s.targets {
+		target, count := int16(-1), int32(0)
+		// classes are in increasing order, so the first of equal counts is the lower class
+		for i, n := range s.borders[slot*nc : (slot+1)*nc] {
+			if n > count {
+				target, count = int16(s.classes[i]), n
+			}
+		}
+		s.targets[slot] = target
+	}
+	s.borders = nil
+}
+
+// target returns the class the region of a root is merged into, if any.
+func (s *sieve) target(root int32) (uint8, bool) {
+	if root < 0 {
+		return 0, false
+	}
+	if slot := s.slot[root]; slot >= 0 && s.targets[slot] >= 0 {
+		return uint8(s.targets[slot]), true
+	}
+	return 0, false
+}
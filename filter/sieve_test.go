@@ -0,0 +1,92 @@
+package filter
+
+import "testing"
+
+func sieveImage(img [][]uint8, threshold int, conn8 bool) [][]uint8 {
+	s := newSieve(len(img[0]), conn8)
+	scan := func(process func(y int, row []uint8, roots []int32)) {
+		s.reset()
+		for y := range img {
+			row := s.row()
+			copy(row, img[y])
+			process(y, row, s.label())
+		}
+	}
+	scan(func(int, []uint8, []int32) {})
+	s.freeze(threshold)
+	scan(func(int, []uint8, []int32) { s.countBorders() })
+	s.decide()
+	res := make([][]uint8, len(img))
+	scan(func(y int, row []uint8, roots []int32) {
+		res[y] = append([]uint8{}, row...)
+		for x, root := range roots {
+			if target, ok := s.target(root); ok {
+				res[y][x] = target
+			}
+		}
+	})
+	return res
+}
+
+func TestSieve(t *testing.T) {
+	img := [][]uint8{
+		{1, 1, 1, 1, 2, 2},
+		{1, 3, 1, 1, 2, 2},
+		{1, 1, 4, 1, 2, 0},
+		{1, 1, 1, 4, 2, 0},
+		{5, 5, 1, 1, 2, 2},
+	}
+	tests := []struct {
+		conn8     bool
+		threshold int
+		expected  [][]uint8
+	}{
+		{true, 2, [][]uint8{
+			{1, 1, 1, 1, 2, 2},
+			{1, 1, 1, 1, 2, 2},
+			{1, 1, 4, 1, 2, 0},
+			{1, 1, 1, 4, 2, 0},
+			{5, 5, 1, 1, 2, 2},
+		}},
+		{false, 2, [][]uint8{
+			{1, 1, 1, 1, 2, 2},
+			{1, 1, 1, 1, 2, 2},
+			{1, 1, 1, 1, 2, 0},
+			{1, 1, 1, 1, 2, 0},
+			{5, 5, 1, 1, 2, 2},
+		}},
+		{true, 3, [][]uint8{
+			{1, 1, 1, 1, 2, 2},
+			{1, 1, 1, 1, 2, 2},
+			{1, 1, 1, 1, 2, 0},
+			{1, 1, 1, 1, 2, 0},
+			{1, 1, 1, 1, 2, 2},
+		}},
+	}
+	for _, tt := range tests {
+		res := sieveImage(img, tt.threshold, tt.conn8)
+		for y := range res {
+			for x := range res[y] {
+				if res[y][x] != tt.expected[y][x] {
+					t.Fatalf("conn8=%v, threshold=%d: at (%d,%d) expected %d, found %d", tt.conn8, tt.threshold, x, y, tt.expected[y][x], res[y][x])
+				}
+			}
+		}
+	}
+}
+
+func TestParseSieve(t *testing.T) {
+	m, conn, err := ParseSieve("sieve:0.5ha:4")
+	if err != nil || m.Hectares != 0.5 || conn != 4 {
+		t.Errorf("unexpected %v, %d, %v", m, conn, err)
+	}
+	if px := m.pixels([6]float64{0, 30, 0, 0, 0, -30}); px != 6 {
+		t.Errorf("expected 6 pixels for 0.5ha, found %d", px)
+	}
+	if m, conn, err = ParseSieve("sieve:10"); err != nil || m.Pixels != 10 || conn != 8 {
+		t.Errorf("unexpected %v, %d, %v", m, conn, err)
+	}
+	if _, _, err = ParseSieve("sieve:10:6"); err == nil {
+		t.Error("expected error")
+	}
+}
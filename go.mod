@@ -9,6 +9,17 @@ require (
 	github.com/tensorflow/tensorflow v2.8.1+incompatible
 	github.com/teris-io/cli v1.0.1
 	github.com/vardius/progress-go v0.0.0-20210725070013-c85a970b9413
+	github.com/xitongsys/parquet-go v1.6.2
+	github.com/xitongsys/parquet-go-source v0.0.0-20200817004010-026bad9b25d0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
-require google.golang.org/protobuf v1.28.0 // indirect
+require (
+	github.com/apache/arrow/go/arrow v0.0.0-20200730104253-651201b0f516 // indirect
+	github.com/apache/thrift v0.14.2 // indirect
+	github.com/golang/snappy v0.0.3 // indirect
+	github.com/klauspost/compress v1.13.1 // indirect
+	github.com/pierrec/lz4/v4 v4.1.8 // indirect
+	golang.org/x/xerrors v0.0.0-20191204190536-9bdfabe68543 // indirect
+	google.golang.org/protobuf v1.28.0 // indirect
+)
@@ -0,0 +1,138 @@
+package io
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// VSITar is the prefix of the GDAL virtual file system reading files in tar archives, gzip-compressed or not.
+const VSITar = "/vsitar/"
+
+// ArchivePattern matches the names of tar archives, gzip-compressed or not.
+const ArchivePattern = `(?i)\.(tar|tar\.gz|tgz)$`
+
+var archiveRe = regexp.MustCompile(ArchivePattern)
+
+// members caches the listings of archives, which require decompressing them.
+var members sync.Map
+
+// IsArchive tells whether the file is a tar archive by its name.
+func IsArchive(fileName string) bool {
+	return archiveRe.MatchString(fileName)
+}
+
+// Join returns the path of a file in a local or remote directory or, as GDAL virtual path, in a tar archive.
+func Join(root, name string) string {
+	switch {
+	case IsArchive(root):
+		return VSITar + root + "/" + name
+	case IsRemote(root):
+		return strings.TrimSuffix(root, "/") + "/" + name
+	}
+	return path.Join(root, name)
+}
+
+// List returns the names of the regular files in a tar archive, in archive order.
+func List(archive string) ([]string, error) {
+	if res, ok := members.Load(archive); ok {
+		return res.([]string), nil
+	}
+	var res []string
+	err := walkArchive(archive, func(h *tar.Header, _ io.Reader) (bool, error) {
+		res = append(res, h.Name)
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	members.Store(archive, res)
+	return res, nil
+}
+
+// Exists tells whether the file exists in a directory or tar archive.
+func Exists(root, name string) bool {
+	if !IsArchive(root) {
+		_, err := Stat(Join(root, name))
+		return err == nil
+	}
+	names, err := List(root)
+	if err != nil {
+		return false
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadFile reads a file in a directory or tar archive.
+func ReadFile(root, name string) ([]byte, error) {
+	if !IsArchive(root) {
+		r, err := Open(Join(root, name))
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		return io.ReadAll(r)
+	}
+	var res []byte
+	found := false
+	err := walkArchive(root, func(h *tar.Header, r io.Reader) (bool, error) {
+		if h.Name != name {
+			return false, nil
+		}
+		found = true
+		var err error
+		res, err = io.ReadAll(r)
+		return true, err
+	})
+	if err == nil && !found {
+		err = fmt.Errorf("%s not found in %s", name, root)
+	}
+	return res, err
+}
+
+// walkArchive calls visit for the regular files of the archive until it is done.
+func walkArchive(archive string, visit func(h *tar.Header, r io.Reader) (bool, error)) error {
+	f, err := Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	// gzip-compressed archives are recognised by content rather than by name
+	var r io.Reader = bufio.NewReader(f)
+	if magic, err := r.(*bufio.Reader).Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", archive, err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", archive, err)
+		}
+		if h.Typeflag != tar.TypeReg {
+			continue
+		}
+		done, err := visit(h, tr)
+		if err != nil || done {
+			return err
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package io
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Inputs expands a command-line input into the files to process: a glob pattern into its matches, a directory
+// into the files directly in it matching the regular expression, and any other name into itself. Hidden files,
+// such as products being written, are left out of globs and directories. Subdirectories are not descended into
+// as they usually hold the products of earlier runs. Remote directories and globs are listed from S3-compatible
+// storage.
+func Inputs(name, pattern string) ([]string, error) {
+	var res []string
+	if IsRemote(name) {
+		var err error
+		if res, err = remoteInputs(name, pattern); err != nil {
+			return nil, err
+		}
+	} else if strings.ContainsAny(name, "*?[") {
+		matches, err := filepath.Glob(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && !info.IsDir() && !hidden(match) {
+				res = append(res, match)
+			}
+		}
+	} else if info, err := os.Stat(name); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return []string{name}, nil
+	} else {
+		matcher, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		entries, err := os.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			if !entry.IsDir() && !hidden(entry.Name()) && matcher.MatchString(entry.Name()) {
+				res = append(res, path.Join(name, entry.Name()))
+			}
+		}
+	}
+	if len(res) == 0 {
+		return nil, fmt.Errorf("no input files found in %s", name)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// remoteInputs expands remote inputs as Inputs does, supporting glob patterns in the last path element only.
+func remoteInputs(name, pattern string) ([]string, error) {
+	dir, glob := name, ""
+	if strings.ContainsAny(path.Base(name), "*?[") {
+		dir, glob = path.Dir(name), path.Base(name)
+	} else if info, err := Stat(name); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return []string{name}, nil
+	}
+	matcher, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := list(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, o := range objects {
+		if o.dir || hidden(o.name) {
+			continue
+		}
+		if ok, _ := path.Match(glob, o.Name()); ok || glob == "" && matcher.MatchString(o.Name()) {
+			res = append(res, o.name)
+		}
+	}
+	return res, nil
+}
+
+func hidden(name string) bool {
+	return strings.HasPrefix(path.Base(name), ".")
+}
@@ -0,0 +1,403 @@
+package io
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// s3Config is the S3 endpoint and credentials, configured by the environment variables GDAL reads for
+// VSIS3 so that one configuration serves both: AWS_S3_ENDPOINT, AWS_HTTPS and AWS_VIRTUAL_HOSTING select the
+// endpoint (for MinIO e.g. localhost:9000, NO and FALSE), AWS_REGION or AWS_DEFAULT_REGION the region and
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN the credentials. Requests are anonymous
+// without credentials or with AWS_NO_SIGN_REQUEST.
+type s3Config struct {
+	endpoint, region            string
+	accessKey, secretKey, token string
+	https, virtualHosting, sign bool
+}
+
+const (
+	s3Service       = "s3"
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3TimeFormat    = "20060102T150405Z"
+	s3DateFormat    = "20060102"
+	unsignedPayload = "UNSIGNED-PAYLOAD"
+	// s3MaxParts is the maximum number of parts of a multipart upload.
+	s3MaxParts = 10000
+)
+
+// s3PartSize is the size of the parts objects larger than that are uploaded in, S3 taking single uploads of up
+// to 5 GB only. It grows for objects of more than the maximum number of parts.
+var s3PartSize int64 = 64 << 20
+
+func s3Env() s3Config {
+	res := s3Config{
+		endpoint:       os.Getenv("AWS_S3_ENDPOINT"),
+		region:         os.Getenv("AWS_REGION"),
+		accessKey:      os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:      os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:          os.Getenv("AWS_SESSION_TOKEN"),
+		https:          !isOff(os.Getenv("AWS_HTTPS")),
+		virtualHosting: !isOff(os.Getenv("AWS_VIRTUAL_HOSTING")),
+	}
+	noSign := os.Getenv("AWS_NO_SIGN_REQUEST")
+	res.sign = res.accessKey != "" && (noSign == "" || isOff(noSign))
+	if res.endpoint == "" {
+		res.endpoint = "s3.amazonaws.com"
+	}
+	if strings.HasPrefix(res.endpoint, "http://") {
+		res.https = false
+	}
+	res.endpoint = strings.TrimPrefix(strings.TrimPrefix(res.endpoint, "http://"), "https://")
+	if res.region == "" {
+		res.region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if res.region == "" {
+		res.region = "us-east-1"
+	}
+	return res
+}
+
+// isOff tells whether a GDAL boolean configuration value is false.
+func isOff(v string) bool {
+	switch strings.ToUpper(v) {
+	case "NO", "FALSE", "OFF", "0":
+		return true
+	}
+	return false
+}
+
+// s3Split splits a VSIS3 path into bucket and key.
+func s3Split(name string) (string, string) {
+	res := strings.SplitN(strings.TrimPrefix(name, VSIS3), "/", 2)
+	if len(res) == 1 {
+		return res[0], ""
+	}
+	return res[0], res[1]
+}
+
+// request sends a signed request for an object, or for the bucket with an empty key.
+func (c s3Config) request(method, bucket, key string, query url.Values, body io.Reader, size int64) (*http.Response, error) {
+	host, p := c.endpoint, "/"+bucket
+	if c.virtualHosting {
+		host, p = bucket+"."+c.endpoint, ""
+	}
+	if key != "" || c.virtualHosting {
+		p += "/" + key
+	}
+	u := &url.URL{Scheme: "https", Host: host, Path: p, RawPath: uriEncode(p, false), RawQuery: canonicalQuery(query)}
+	if !c.https {
+		u.Scheme = "http"
+	}
+	req, err := http.NewRequest(method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if c.sign {
+		c.signRequest(req, time.Now().UTC(), unsignedPayload)
+	}
+	return client.Do(req)
+}
+
+// signRequest adds the AWS signature version 4 authorization of the request with the hash of its payload,
+// requests of this package leaving it unsigned. The host, date and range headers are signed when given, as are
+// all x-amz- headers. The query must be in canonical form.
+func (c s3Config) signRequest(req *http.Request, now time.Time, payloadHash string) {
+	req.Header.Set("x-amz-date", now.Format(s3TimeFormat))
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+	if c.token != "" {
+		req.Header.Set("x-amz-security-token", c.token)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for k := range req.Header {
+		if k := strings.ToLower(k); strings.HasPrefix(k, "x-amz-") || k == "date" || k == "range" {
+			headers[k] = strings.TrimSpace(req.Header.Get(k))
+		}
+	}
+	var names []string
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery,
+		canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+
+	scope := strings.Join([]string{now.Format(s3DateFormat), c.region, s3Service, "aws4_request"}, "/")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{s3Algorithm, now.Format(s3TimeFormat), scope, hex.EncodeToString(hash[:])}, "\n")
+	key := []byte("AWS4" + c.secretKey)
+	for _, part := range []string{now.Format(s3DateFormat), c.region, s3Service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, c.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode percent-encodes all but the unreserved characters as required for signing, keeping slashes
+// unless requested otherwise.
+func uriEncode(s string, encodeSlash bool) string {
+	var res strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_', b == '.', b == '~',
+			b == '/' && !encodeSlash:
+			res.WriteByte(b)
+		default:
+			fmt.Fprintf(&res, "%%%02X", b)
+		}
+	}
+	return res.String()
+}
+
+func canonicalQuery(query url.Values) string {
+	var res []string
+	for k, vs := range query {
+		for _, v := range vs {
+			res = append(res, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	sort.Strings(res)
+	return strings.Join(res, "&")
+}
+
+func s3Stat(name string) (fs.FileInfo, error) {
+	bucket, key := s3Split(name)
+	if key != "" && !strings.HasSuffix(key, "/") {
+		resp, err := s3Env().request(http.MethodHead, bucket, key, nil, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		_ = resp.Body.Close()
+		if err = checkResponse("stat", name, resp); err == nil {
+			return responseInfo(name, resp), nil
+		} else if resp.StatusCode != http.StatusNotFound {
+			return nil, err
+		}
+	}
+	// prefixes holding objects are directories
+	res, err := s3ListPage(bucket, dirPrefix(key), "/", "", 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Contents) == 0 && len(res.CommonPrefixes) == 0 {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	return &fileInfo{name: strings.TrimSuffix(name, "/"), dir: true}, nil
+}
+
+func s3Get(name string) (io.ReadCloser, error) {
+	bucket, key := s3Split(name)
+	resp, err := s3Env().request(http.MethodGet, bucket, key, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkResponse("open", name, resp); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// s3Put uploads an object, in parts if larger than the part size.
+func s3Put(name string, body io.Reader, size int64) error {
+	if size > s3PartSize {
+		return s3PutParts(name, body, size)
+	}
+	bucket, key := s3Split(name)
+	resp, err := s3Env().request(http.MethodPut, bucket, key, nil, body, size)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	return checkResponse("write", name, resp)
+}
+
+// s3Upload is the response of CreateMultipartUpload.
+type s3Upload struct {
+	UploadId string
+}
+
+// s3CompleteUpload is the request of CompleteMultipartUpload.
+type s3CompleteUpload struct {
+	XMLName xml.Name `xml:"CompleteMultipartUpload"`
+	Parts   []s3Part `xml:"Part"`
+}
+
+type s3Part struct {
+	PartNumber int
+	ETag       string
+}
+
+// s3PutParts uploads an object in a multipart upload, which is aborted on failure so that no parts are left
+// stored.
+func s3PutParts(name string, body io.Reader, size int64) (err error) {
+	c := s3Env()
+	bucket, key := s3Split(name)
+	resp, err := c.request(http.MethodPost, bucket, key, url.Values{"uploads": {""}}, nil, 0)
+	if err != nil {
+		return err
+	}
+	var upload s3Upload
+	if err = checkResponse("write", name, resp); err == nil {
+		err = xml.NewDecoder(resp.Body).Decode(&upload)
+	}
+	_ = resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err == nil {
+			return
+		}
+		if resp, err := c.request(http.MethodDelete, bucket, key, url.Values{"uploadId": {upload.UploadId}}, nil, 0); err == nil {
+			_ = resp.Body.Close()
+		}
+	}()
+
+	partSize := s3PartSize
+	if minSize := (size + s3MaxParts - 1) / s3MaxParts; minSize > partSize {
+		partSize = minSize
+	}
+	var complete s3CompleteUpload
+	for offset, i := int64(0), 1; offset < size; offset, i = offset+partSize, i+1 {
+		n := partSize
+		if size-offset < n {
+			n = size - offset
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(i)}, "uploadId": {upload.UploadId}}
+		if resp, err = c.request(http.MethodPut, bucket, key, query, io.LimitReader(body, n), n); err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if err = checkResponse("write", name, resp); err != nil {
+			return err
+		}
+		complete.Parts = append(complete.Parts, s3Part{PartNumber: i, ETag: resp.Header.Get("ETag")})
+	}
+
+	data, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	query := url.Values{"uploadId": {upload.UploadId}}
+	if resp, err = c.request(http.MethodPost, bucket, key, query, bytes.NewReader(data), int64(len(data))); err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err = checkResponse("write", name, resp); err != nil {
+		return err
+	}
+	// completion may fail after the response status was sent, reported by an error document instead
+	var res struct {
+		XMLName xml.Name
+		Code    string
+		Message string
+	}
+	if err = xml.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return err
+	}
+	if res.XMLName.Local == "Error" {
+		return &fs.PathError{Op: "write", Path: name, Err: fmt.Errorf("%s: %s", res.Code, res.Message)}
+	}
+	return nil
+}
+
+// s3ListResult is the response of ListObjectsV2.
+type s3ListResult struct {
+	Contents []struct {
+		Key          string
+		LastModified time.Time
+		Size         int64
+	}
+	CommonPrefixes []struct {
+		Prefix string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+func s3ListPage(bucket, prefix, delimiter, token string, maxKeys int) (*s3ListResult, error) {
+	query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	if maxKeys > 0 {
+		query.Set("max-keys", fmt.Sprint(maxKeys))
+	}
+	resp, err := s3Env().request(http.MethodGet, bucket, "", query, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err = checkResponse("list", VSIS3+bucket+"/"+prefix, resp); err != nil {
+		return nil, err
+	}
+	res := &s3ListResult{}
+	return res, xml.NewDecoder(resp.Body).Decode(res)
+}
+
+// s3List returns the objects under the prefix, directly together with the prefixes they share as
+// directories, or at any depth.
+func s3List(root string, recursive bool) ([]*fileInfo, error) {
+	var res []*fileInfo
+	bucket, key := s3Split(root)
+	prefix, delimiter, token := dirPrefix(key), "/", ""
+	if recursive {
+		delimiter = ""
+	}
+	for {
+		page, err := s3ListPage(bucket, prefix, delimiter, token, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range page.Contents {
+			res = append(res, &fileInfo{name: VSIS3 + bucket + "/" + o.Key, size: o.Size, modTime: o.LastModified})
+		}
+		for _, p := range page.CommonPrefixes {
+			res = append(res, &fileInfo{name: VSIS3 + bucket + "/" + strings.TrimSuffix(p.Prefix, "/"), dir: true})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return res, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// dirPrefix returns the key prefix of the objects in a directory, empty for the bucket.
+func dirPrefix(key string) string {
+	if key = strings.TrimSuffix(key, "/"); key == "" {
+		return ""
+	}
+	return key + "/"
+}
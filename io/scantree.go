@@ -16,6 +16,18 @@ func ScanTree(root, pattern string) ([]string, error) {
 
 func scanWithMatcher(root string, matcher *regexp.Regexp) ([]string, error) {
 	var res []string
+	if IsRemote(root) {
+		objects, err := list(root, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range objects {
+			if !o.dir && matcher.MatchString(o.Name()) {
+				res = append(res, o.name)
+			}
+		}
+		return res, nil
+	}
 	infos, err := ioutil.ReadDir(root)
 	if err != nil {
 		return nil, err
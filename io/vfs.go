@@ -0,0 +1,286 @@
+package io
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Remote storage is addressed by the paths of the GDAL virtual file systems, which GDAL reads directly: VSIS3
+// for S3-compatible object storage such as AWS S3 or MinIO, and VSICurl for files served over HTTP(S). The
+// functions below serve everything GDAL does not, such as listings, metadata files and uploads, and fall back
+// to the local file system for other paths.
+const (
+	VSIS3   = "/vsis3/"
+	VSICurl = "/vsicurl/"
+)
+
+// client bounds the time to connect to remote storage and to wait for responses, but not that of transfers,
+// which take long for large objects.
+var client = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		ExpectContinueTimeout: time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerHost:   8,
+	},
+}
+
+// Path translates s3:// and http(s):// URLs into GDAL virtual file system paths and leaves other names
+// unchanged.
+func Path(name string) string {
+	switch {
+	case strings.HasPrefix(name, "s3://"):
+		return VSIS3 + strings.TrimPrefix(name, "s3://")
+	case strings.HasPrefix(name, "http://"), strings.HasPrefix(name, "https://"):
+		return VSICurl + name
+	}
+	return name
+}
+
+// IsRemote tells whether the path is in remote storage.
+func IsRemote(name string) bool {
+	return strings.HasPrefix(name, VSIS3) || strings.HasPrefix(name, VSICurl)
+}
+
+// Stat describes a local file or a remote object. Remote prefixes holding objects are described as
+// directories.
+func Stat(name string) (fs.FileInfo, error) {
+	switch {
+	case strings.HasPrefix(name, VSIS3):
+		return s3Stat(name)
+	case strings.HasPrefix(name, VSICurl):
+		return curlStat(name)
+	}
+	return os.Stat(name)
+}
+
+// Open opens a local file or a remote object for reading.
+func Open(name string) (io.ReadCloser, error) {
+	switch {
+	case strings.HasPrefix(name, VSIS3):
+		return s3Get(name)
+	case strings.HasPrefix(name, VSICurl):
+		return curlGet(name)
+	}
+	return os.Open(name)
+}
+
+// WriteFile writes a local file or an S3 object.
+func WriteFile(name string, data []byte) error {
+	switch {
+	case strings.HasPrefix(name, VSIS3):
+		return s3Put(name, bytes.NewReader(data), int64(len(data)))
+	case strings.HasPrefix(name, VSICurl):
+		return fmt.Errorf("cannot write %s: HTTP storage is read-only", name)
+	}
+	return os.WriteFile(name, data, 0644)
+}
+
+// Upload copies a local file to a local or S3 destination.
+func Upload(fileName, name string) error {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	switch {
+	case strings.HasPrefix(name, VSIS3):
+		return s3Put(name, f, info.Size())
+	case strings.HasPrefix(name, VSICurl):
+		return fmt.Errorf("cannot write %s: HTTP storage is read-only", name)
+	}
+	out, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(out, f); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// File is a file being written to its local name or, for a remote name, to its staging file, which Commit
+// uploads.
+type File struct {
+	*os.File
+	name string
+}
+
+// Create creates a file for writing, staged locally for remote names as Staging does.
+func Create(name string) (*File, error) {
+	local := Staging(name)
+	if err := MkdirAll(path.Dir(local)); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(local)
+	if err != nil {
+		return nil, err
+	}
+	return &File{File: f, name: name}, nil
+}
+
+// Commit closes the file and uploads a staged file to its remote name, removing the staged copy.
+func (f *File) Commit() error {
+	if err := f.File.Close(); err != nil {
+		return err
+	}
+	if f.Name() == f.name {
+		return nil
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+	return Upload(f.Name(), f.name)
+}
+
+// MkdirAll creates a local directory with its parents. Remote storage has no directories to create.
+func MkdirAll(name string) error {
+	if IsRemote(name) {
+		return nil
+	}
+	return os.MkdirAll(name, 0750)
+}
+
+// Staging returns the local file a remote product is written to before being uploaded, mirroring its remote
+// path under the temporary directory so that interrupted writes can resume. Local names are returned
+// unchanged.
+func Staging(name string) string {
+	if !IsRemote(name) {
+		return name
+	}
+	return path.Join(os.TempDir(), "landsat", strings.TrimPrefix(strings.TrimPrefix(name, VSIS3), VSICurl))
+}
+
+// Modified returns the latest modification time of a file or of the files under a directory, local or
+// remote.
+func Modified(name string) (time.Time, error) {
+	var res time.Time
+	info, err := Stat(name)
+	if err != nil {
+		return res, err
+	}
+	if !info.IsDir() {
+		return info.ModTime(), nil
+	}
+	if strings.HasPrefix(name, VSIS3) {
+		objects, err := s3List(name, true)
+		if err != nil {
+			return res, err
+		}
+		for _, o := range objects {
+			if o.ModTime().After(res) {
+				res = o.ModTime()
+			}
+		}
+		return res, nil
+	}
+	err = filepath.WalkDir(name, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err == nil && info.ModTime().After(res) {
+			res = info.ModTime()
+		}
+		return err
+	})
+	return res, err
+}
+
+// list returns the remote objects under the prefix, directly or at any depth.
+func list(root string, recursive bool) ([]*fileInfo, error) {
+	if strings.HasPrefix(root, VSIS3) {
+		return s3List(root, recursive)
+	}
+	return nil, fmt.Errorf("cannot list %s: HTTP storage does not support listing", root)
+}
+
+// fileInfo describes a remote object or prefix.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+func (fi *fileInfo) Name() string       { return path.Base(fi.name) }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+func (fi *fileInfo) Mode() fs.FileMode {
+	if fi.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// curlURL returns the URL of a VSICurl path, repairing the scheme separator collapsed by path.Join and
+// path.Dir.
+func curlURL(name string) string {
+	res := strings.TrimPrefix(name, VSICurl)
+	for _, scheme := range []string{"http:/", "https:/"} {
+		if strings.HasPrefix(res, scheme) && !strings.HasPrefix(res, scheme+"/") {
+			res = scheme + "/" + strings.TrimPrefix(res, scheme)
+		}
+	}
+	return res
+}
+
+func curlStat(name string) (fs.FileInfo, error) {
+	resp, err := client.Head(curlURL(name))
+	if err != nil {
+		return nil, err
+	}
+	_ = resp.Body.Close()
+	if err = checkResponse("stat", name, resp); err != nil {
+		return nil, err
+	}
+	return responseInfo(name, resp), nil
+}
+
+func curlGet(name string) (io.ReadCloser, error) {
+	resp, err := client.Get(curlURL(name))
+	if err != nil {
+		return nil, err
+	}
+	if err = checkResponse("open", name, resp); err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// responseInfo describes the object of a HEAD or GET response.
+func responseInfo(name string, resp *http.Response) *fileInfo {
+	res := &fileInfo{name: name, size: resp.ContentLength}
+	res.modTime, _ = http.ParseTime(resp.Header.Get("Last-Modified"))
+	return res
+}
+
+// checkResponse turns unsuccessful responses into errors, missing objects into fs.ErrNotExist.
+func checkResponse(op, name string, resp *http.Response) error {
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case resp.StatusCode >= 300:
+		return &fs.PathError{Op: op, Path: name, Err: fmt.Errorf("%s", resp.Status)}
+	}
+	return nil
+}
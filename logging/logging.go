@@ -0,0 +1,177 @@
+// Package logging provides levelled log lines, rendered as text or JSON, and progress reporting of long-running
+// processing, rendered as a terminal bar, as log lines or as JSON events.
+//
+// Library packages log through the default logger, which applications embedding them may replace.
+package logging
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Level is the severity of log lines.
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"DEBUG", "INFO", "WARN", "ERROR"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses the level names debug, info, warn and error, ignoring case.
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(name, n) {
+			return Level(i), nil
+		}
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %s, expected one of debug, info, warn, error", name)
+}
+
+// Log line formats.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Options configure a logger. Zero values log text lines from the info level on and render progress as bars.
+type Options struct {
+	Level Level
+	// Format is either text or json.
+	Format string
+	// Progress is either bar, log, json or none.
+	Progress string
+}
+
+// Logger writes log lines of key-value pairs at or above its level. Loggers are safe for concurrent use.
+type Logger struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	opts   Options
+	fields []interface{}
+}
+
+// New creates a logger writing to out.
+func New(out io.Writer, opts Options) (*Logger, error) {
+	if opts.Format == "" {
+		opts.Format = FormatText
+	}
+	if opts.Format != FormatText && opts.Format != FormatJSON {
+		return nil, fmt.Errorf("unknown log format %s, expected text or json", opts.Format)
+	}
+	if opts.Progress == "" {
+		opts.Progress = ProgressBar
+	}
+	switch opts.Progress {
+	case ProgressBar, ProgressLog, ProgressJSON, ProgressNone:
+	default:
+		return nil, fmt.Errorf("unknown progress mode %s, expected bar, log, json or none", opts.Progress)
+	}
+	return &Logger{mu: &sync.Mutex{}, out: out, opts: opts}, nil
+}
+
+var std, _ = New(os.Stderr, Options{})
+
+// Default returns the logger used by the package-level functions and library packages.
+func Default() *Logger {
+	return std
+}
+
+// SetDefault replaces the default logger.
+func SetDefault(l *Logger) {
+	std = l
+}
+
+// With returns a logger adding the key-value pairs to every line.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	res := *l
+	res.fields = append(append([]interface{}{}, l.fields...), kv...)
+	return &res
+}
+
+// Enabled tells whether lines of the level are written.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.opts.Level
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.log(LevelDebug, msg, kv) }
+func (l *Logger) Info(msg string, kv ...interface{})  { l.log(LevelInfo, msg, kv) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { l.log(LevelWarn, msg, kv) }
+func (l *Logger) Error(msg string, kv ...interface{}) { l.log(LevelError, msg, kv) }
+
+func Debug(msg string, kv ...interface{}) { std.log(LevelDebug, msg, kv) }
+func Info(msg string, kv ...interface{})  { std.log(LevelInfo, msg, kv) }
+func Warn(msg string, kv ...interface{})  { std.log(LevelWarn, msg, kv) }
+func Error(msg string, kv ...interface{}) { std.log(LevelError, msg, kv) }
+
+func (l *Logger) log(level Level, msg string, kv []interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+	l.write(l.opts.Format, level, msg, append(append([]interface{}{}, l.fields...), kv...))
+}
+
+func (l *Logger) write(format string, level Level, msg string, kv []interface{}) {
+	now := time.Now().UTC().Format(time.RFC3339)
+	var b strings.Builder
+	if format == FormatJSON {
+		b.WriteString(`{"time":` + quote(now) + `,"level":` + quote(level.String()) + `,"msg":` + quote(msg))
+		for i := 0; i < len(kv); i += 2 {
+			b.WriteString("," + quote(key(kv, i)) + ":")
+			v, err := json.Marshal(value(kv, i))
+			if err != nil {
+				v = []byte(quote(fmt.Sprint(value(kv, i))))
+			}
+			b.Write(v)
+		}
+		b.WriteString("}\n")
+	} else {
+		b.WriteString(now + " " + level.String() + " " + msg)
+		for i := 0; i < len(kv); i += 2 {
+			v := fmt.Sprint(value(kv, i))
+			if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+				v = quote(v)
+			}
+			b.WriteString(" " + key(kv, i) + "=" + v)
+		}
+		b.WriteString("\n")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = io.WriteString(l.out, b.String())
+}
+
+func key(kv []interface{}, i int) string {
+	return fmt.Sprint(kv[i])
+}
+
+// value returns the value of the key at i, a dangling key has none.
+func value(kv []interface{}, i int) interface{} {
+	if i+1 < len(kv) {
+		if err, ok := kv[i+1].(error); ok {
+			return err.Error()
+		}
+		return kv[i+1]
+	}
+	return nil
+}
+
+func quote(s string) string {
+	res, _ := json.Marshal(s)
+	return string(res)
+}
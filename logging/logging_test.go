@@ -0,0 +1,75 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&buf, Options{Level: LevelWarn, Format: FormatJSON})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info("hidden")
+	l.With("input", "a b.tiff").Error("failed", "error", errors.New("boom"), "rows", 3)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected a single line, found %q", buf.String())
+	}
+	var line map[string]interface{}
+	if err = json.Unmarshal([]byte(lines[0]), &line); err != nil {
+		t.Fatal(err)
+	}
+	if line["level"] != "ERROR" || line["msg"] != "failed" || line["input"] != "a b.tiff" ||
+		line["error"] != "boom" || line["rows"] != 3. {
+		t.Errorf("unexpected line %v", line)
+	}
+}
+
+func TestText(t *testing.T) {
+	var buf bytes.Buffer
+	l, _ := New(&buf, Options{})
+	l.Info("running", "command", "predict x.tiff", "workers", 2)
+	if !strings.HasSuffix(buf.String(), ` INFO running command="predict x.tiff" workers=2`+"\n") {
+		t.Errorf("unexpected line %q", buf.String())
+	}
+}
+
+func TestProgressEvents(t *testing.T) {
+	var buf bytes.Buffer
+	l, _ := New(&buf, Options{Level: LevelError, Progress: ProgressJSON})
+	p := l.NewProgress("trim", 0, 100)
+	p.Start()
+	for i := 0; i < 100; i++ {
+		p.Advance(1)
+	}
+	p.Stop()
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	// started, every 10 percent and finished
+	if len(lines) != 12 {
+		t.Fatalf("expected 12 events, found %d: %s", len(lines), buf.String())
+	}
+	var last map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[11]), &last); err != nil {
+		t.Fatal(err)
+	}
+	if last["msg"] != "finished" || last["task"] != "trim" || last["percent"] != 100. {
+		t.Errorf("unexpected event %v", last)
+	}
+}
+
+func TestOptionErrors(t *testing.T) {
+	if _, err := New(nil, Options{Format: "xml"}); err == nil {
+		t.Error("expected format error")
+	}
+	if _, err := New(nil, Options{Progress: "spinner"}); err == nil {
+		t.Error("expected progress error")
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected level error")
+	}
+}
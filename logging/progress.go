@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"time"
+
+	"github.com/vardius/progress-go"
+)
+
+// Progress rendering modes.
+const (
+	// ProgressBar draws a terminal bar.
+	ProgressBar = "bar"
+	// ProgressLog writes log lines in the log format.
+	ProgressLog = "log"
+	// ProgressJSON writes JSON events whatever the log format.
+	ProgressJSON = "json"
+	// ProgressNone reports nothing.
+	ProgressNone = "none"
+)
+
+// progressStep is the percentage between progress log lines and events.
+const progressStep = 10
+
+// Progress reports the advance of a task from its start towards its maximum.
+type Progress interface {
+	Start()
+	Advance(n int64)
+	Stop()
+}
+
+// NewProgress creates a progress report of the task with the default logger.
+func NewProgress(task string, start, max int64) Progress {
+	return std.NewProgress(task, start, max)
+}
+
+// NewProgress creates a progress report of the task rendered according to the progress mode of the logger.
+func (l *Logger) NewProgress(task string, start, max int64) Progress {
+	switch l.opts.Progress {
+	case ProgressNone:
+		return noProgress{}
+	case ProgressBar:
+		return &bar{progress.New(start, max, progress.Options{Output: &lockedWriter{l}})}
+	}
+	format := l.opts.Format
+	if l.opts.Progress == ProgressJSON {
+		format = FormatJSON
+	}
+	return &events{l: l, format: format, task: task, start: start, step: start, max: max}
+}
+
+type noProgress struct{}
+
+func (noProgress) Start()        {}
+func (noProgress) Advance(int64) {}
+func (noProgress) Stop()         {}
+
+type bar struct {
+	b *progress.Bar
+}
+
+func (b *bar) Start()          { _, _ = b.b.Start() }
+func (b *bar) Advance(n int64) { _, _ = b.b.Advance(n) }
+func (b *bar) Stop()           { _, _ = b.b.Stop() }
+
+// lockedWriter serialises bar output with log lines.
+type lockedWriter struct {
+	l *Logger
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.l.mu.Lock()
+	defer w.l.mu.Unlock()
+	return w.l.out.Write(p)
+}
+
+// events writes a progress line or event every progressStep percent, independent of the log level.
+type events struct {
+	l          *Logger
+	format     string
+	task       string
+	start, max int64
+	step       int64
+	reported   int64
+	started    time.Time
+}
+
+func (e *events) Start() {
+	e.started = time.Now()
+	e.reported = e.percent()
+	e.emit("started")
+}
+
+func (e *events) Advance(n int64) {
+	e.step += n
+	if p := e.percent(); p >= e.reported+progressStep {
+		e.reported = p - p%progressStep
+		e.emit("progress")
+	}
+}
+
+func (e *events) Stop() {
+	e.step = e.max
+	e.reported = 100
+	e.emit("finished", "seconds", time.Since(e.started).Seconds())
+}
+
+func (e *events) percent() int64 {
+	if e.max <= e.start {
+		return 100
+	}
+	return 100 * (e.step - e.start) / (e.max - e.start)
+}
+
+func (e *events) emit(msg string, kv ...interface{}) {
+	kv = append([]interface{}{"task", e.task, "done", e.step - e.start, "total", e.max - e.start, "percent", e.percent()}, kv...)
+	e.l.write(e.format, LevelInfo, msg, append(append([]interface{}{}, e.l.fields...), kv...))
+}
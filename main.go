@@ -1,21 +1,37 @@
 package main
 
 import (
+	"fmt"
 	"github.com/nordicsense/landsat/classification"
-	"log"
 	"os"
 	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/nordicsense/gdal"
+	"github.com/nordicsense/landsat/catalog"
 	"github.com/nordicsense/landsat/change"
 	"github.com/nordicsense/landsat/conversion"
+	"github.com/nordicsense/landsat/data"
+	"github.com/nordicsense/landsat/dataset"
 	"github.com/nordicsense/landsat/filter"
 	"github.com/nordicsense/landsat/io"
+	"github.com/nordicsense/landsat/logging"
+	"github.com/nordicsense/landsat/pipeline"
+	"github.com/nordicsense/landsat/product"
+	"github.com/nordicsense/landsat/provenance"
+	"github.com/nordicsense/landsat/subset"
 	"github.com/nordicsense/landsat/trim"
+	"github.com/nordicsense/landsat/vector"
 	"github.com/teris-io/cli"
 )
 
+// tiffPattern matches the images processed in directories given as input.
+const tiffPattern = `(?i)\.tiff?$`
+
 // run with e.g. compress=deflate zlevel=6 predictor=3
 // best for float32, see https://kokoalberti.com/articles/geotiff-compression-optimization-guide/
 func main() {
@@ -27,71 +43,201 @@ func main() {
 		WithOption(cli.NewOption("verbose", "Verbose mode").WithChar('v').WithType(cli.TypeBool)).
 		WithOption(cli.NewOption("l1", "L1 (default: L2, off)").WithChar('l').WithType(cli.TypeBool)).
 		WithOption(cli.NewOption("skip", "Skip existing").WithChar('s').WithType(cli.TypeBool)).
-		WithAction(convertAction)
+		WithOption(cli.NewOption("sidecar", "Write provenance also into a JSON sidecar").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("compress", "Compression profile: none, lzw, deflate, zstd")).
+		WithOption(cli.NewOption("overviews", "Build internal overviews").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("cog", "Write Cloud-Optimized GeoTIFF with tiles and overviews").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("from", "Select scenes acquired on or after the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("to", "Select scenes acquired on or before the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("doy", "Select scenes acquired within the day-of-year range, e.g. 170-240")).
+		WithOption(cli.NewOption("max-cloud", "Select scenes of at most the cloud cover in percent").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("pathrow", "Select scenes of the WRS-2 path/rows, e.g. 188/012,190/011")).
+		WithAction(action(convertAction))
 
 	trainingCmd := cli.NewCommand("training", "Collect training data from field data").
 		WithShortcut("t").
 		WithArg(cli.NewArg("coorddir", "Directory with coordinate files")).
 		WithOption(cli.NewOption("input", "Input directory for images (default: current)").WithChar('d')).
 		WithOption(cli.NewOption("output", "Output directory for training data (default: current)").WithChar('o')).
+		WithOption(cli.NewOption("format", "Training data format: csv, parquet or npz (default: csv)").WithChar('f')).
+		WithOption(cli.NewOption("sample-window", "Odd side in pixels of the window sampled around field coordinates (default: 1)").WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("sample-radius", "Radius in metres of pixels sampled around field coordinates instead of a window").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("max-variance", "Reject sampling windows of higher mean band variance").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("temporal", "Sample temporal features over the selected scenes of the same path/row and year").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("split", "Split strategy keeping groups of samples together: random, source, image or block (default: random)")).
+		WithOption(cli.NewOption("block-size", "Side of spatial blocks in km for the block split (default: 10)").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("folds", "Number of cross-validation folds written instead of a single split").WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("seed", "Seed of the random split (default: 42)").WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("train-fraction", "Fraction of the samples of a class used for training (default: 0.8)").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("class-size", "Maximum samples of a class for training and testing (default: 40000)").WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("test-size", "Maximum test samples of a class (default: 3000)").WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("balance", "Class balancing of training data: none, undersample, oversample, smote or weights (default: none)")).
+		WithOption(cli.NewOption("jitter", "Noise of oversampled copies relative to the class standard deviation (default: 0.1)").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("neighbours", "Nearest neighbours synthetic SMOTE samples are interpolated towards (default: 5)").WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("qc", "Write spectral outliers, class boundary and cloud-like samples into a review report; cloud-like is spectral similarity to the cloud class, not a cloud mask").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("exclude-flagged", "Exclude the samples flagged by quality control").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("outlier-quantile", "Chi-square quantile of Mahalanobis distances beyond which samples are outliers (default: 0.999)").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("boundary-margin", "Flag samples closer to another class than the margin times to their own (default: 1)").WithType(cli.TypeNumber)).
 		// WithOption(cli.NewOption("verbose", "Verbose mode").WithChar('v').WithType(cli.TypeBool)).
-		WithAction(fieldDataAction)
+		WithOption(cli.NewOption("from", "Select scenes acquired on or after the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("to", "Select scenes acquired on or before the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("doy", "Select scenes acquired within the day-of-year range, e.g. 170-240")).
+		WithOption(cli.NewOption("max-cloud", "Select scenes of at most the cloud cover in percent").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("pathrow", "Select scenes of the WRS-2 path/rows, e.g. 188/012,190/011")).
+		WithAction(action(fieldDataAction))
 
 	predictCmd := cli.NewCommand("predict", "Predict land cover classes with Tensorflow classification").
 		WithShortcut("p").
-		WithArg(cli.NewArg("data", "Multi-band Landsat GeoTiff with 7 bands of input data")).
+		WithArg(cli.NewArg("data", "Multi-band Landsat GeoTiff with 7 bands of input data, a directory or glob of them")).
 		WithOption(cli.NewOption("model", "Tensorflow model directory (default: ./tf.model)").WithChar('m')).
 		WithOption(cli.NewOption("output", "Output directory (default: same as input)").WithChar('o')).
-		WithOption(cli.NewOption("id", "Landsat series Id (5, 7 (default), or 8)").WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("id", "Landsat series Id (5, 7, or 8; default: from image metadata)").WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("temporal", "Classify temporal features of the seasons of co-registered images, by path/row and year").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("window", "Pixel window x,y,width,height (default: full image)")).
+		WithOption(cli.NewOption("aoi", "Window as the bounding box of a GeoJSON or other vector file, or .wkt file")).
+		WithOption(cli.NewOption("bbox", "Window as bounding box west,south,east,north")).
+		WithOption(cli.NewOption("epsg", "EPSG code of the bounding box and of .wkt files (default: 4326)").WithType(cli.TypeInt)).
 		WithOption(cli.NewOption("skip", "Skip existing").WithChar('s').WithType(cli.TypeBool)).
 		WithOption(cli.NewOption("verbose", "Verbose mode").WithChar('v').WithType(cli.TypeBool)).
-		WithAction(predictAction)
+		WithOption(cli.NewOption("sidecar", "Write provenance also into a JSON sidecar").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("compress", "Compression profile: none, lzw, deflate, zstd")).
+		WithOption(cli.NewOption("overviews", "Build internal overviews").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("cog", "Write Cloud-Optimized GeoTIFF with tiles and overviews").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("workers", "Images processed in parallel (default: 1)").WithChar('w').WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("from", "Select scenes acquired on or after the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("to", "Select scenes acquired on or before the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("doy", "Select scenes acquired within the day-of-year range, e.g. 170-240")).
+		WithOption(cli.NewOption("max-cloud", "Select scenes of at most the cloud cover in percent").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("pathrow", "Select scenes of the WRS-2 path/rows, e.g. 188/012,190/011")).
+		WithAction(action(predictAction))
 
 	filterCmd := cli.NewCommand("filter", "Filter output with a smoothing filter").
 		WithShortcut("f").
-		WithArg(cli.NewArg("algo", "Filtering algorithm: 3x3, 5x5")).
-		WithArg(cli.NewArg("data", "Classification uni-band")).
+		WithArg(cli.NewArg("algo", "Modal filter kernel: 3x3, 5x5, square:N, circle:N (optionally :centre=W), weights:W,W,...; or sieve:N (pixels) or sieve:Aha (hectares), optionally :4 or :8 connectivity")).
+		WithArg(cli.NewArg("data", "Classification uni-band, a directory or glob of them")).
 		WithOption(cli.NewOption("output", "Output directory (default: same as input)").WithChar('o')).
 		WithOption(cli.NewOption("skip", "Skip existing").WithChar('s').WithType(cli.TypeBool)).
 		WithOption(cli.NewOption("verbose", "Verbose mode").WithChar('v').WithType(cli.TypeBool)).
-		WithAction(filterAction)
+		WithOption(cli.NewOption("sidecar", "Write provenance also into a JSON sidecar").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("compress", "Compression profile: none, lzw, deflate, zstd")).
+		WithOption(cli.NewOption("overviews", "Build internal overviews").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("cog", "Write Cloud-Optimized GeoTIFF with tiles and overviews").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("workers", "Images processed in parallel (default: 1)").WithChar('w').WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("from", "Select scenes acquired on or after the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("to", "Select scenes acquired on or before the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("doy", "Select scenes acquired within the day-of-year range, e.g. 170-240")).
+		WithOption(cli.NewOption("max-cloud", "Select scenes of at most the cloud cover in percent").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("pathrow", "Select scenes of the WRS-2 path/rows, e.g. 188/012,190/011")).
+		WithAction(action(filterAction))
 
-	trimCmd := cli.NewCommand("trim", "Filter output with a smoothing filter").
-		WithArg(cli.NewArg("data", "Image to trim")).
+	trimCmd := cli.NewCommand("trim", "Trim classification to an area of interest").
+		WithArg(cli.NewArg("data", "Image to trim, a directory or glob of them")).
 		WithOption(cli.NewOption("output", "Output directory (default: same as input)").WithChar('o')).
+		WithOption(cli.NewOption("aoi", "Area of interest: GeoJSON or other vector file, or .wkt file")).
+		WithOption(cli.NewOption("bbox", "Area of interest as bounding box west,south,east,north")).
+		WithOption(cli.NewOption("epsg", "EPSG code of the bounding box and of .wkt files (default: 4326)").WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("outside", "Keep the outside of the area of interest").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("crop", "Crop the output to the bounding box of the area of interest").WithType(cli.TypeBool)).
 		WithOption(cli.NewOption("skip", "Skip existing").WithChar('s').WithType(cli.TypeBool)).
 		WithOption(cli.NewOption("verbose", "Verbose mode").WithChar('v').WithType(cli.TypeBool)).
-		WithAction(trimAction)
+		WithOption(cli.NewOption("sidecar", "Write provenance also into a JSON sidecar").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("compress", "Compression profile: none, lzw, deflate, zstd")).
+		WithOption(cli.NewOption("overviews", "Build internal overviews").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("cog", "Write Cloud-Optimized GeoTIFF with tiles and overviews").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("workers", "Images processed in parallel (default: 1)").WithChar('w').WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("from", "Select scenes acquired on or after the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("to", "Select scenes acquired on or before the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("doy", "Select scenes acquired within the day-of-year range, e.g. 170-240")).
+		WithOption(cli.NewOption("max-cloud", "Select scenes of at most the cloud cover in percent").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("pathrow", "Select scenes of the WRS-2 path/rows, e.g. 188/012,190/011")).
+		WithAction(action(trimAction))
 
 	changeCmd := cli.NewCommand("change", "Change detection").
 		WithArg(cli.NewArg("from", "2 from images")).
 		WithArg(cli.NewArg("to", "2 to images")).
 		WithOption(cli.NewOption("output", "Output directory (default: same as input)").WithChar('o')).
-		WithAction(changeAction)
+		WithOption(cli.NewOption("aoi", "Region as the bounding box of a GeoJSON or other vector file, or .wkt file")).
+		WithOption(cli.NewOption("bbox", "Region as bounding box west,south,east,north")).
+		WithOption(cli.NewOption("epsg", "EPSG code of the bounding box and of .wkt files (default: 4326)").WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("verbose", "Verbose mode").WithChar('v').WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("sidecar", "Write provenance also into a JSON sidecar").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("compress", "Compression profile: none, lzw, deflate, zstd")).
+		WithOption(cli.NewOption("overviews", "Build internal overviews").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("cog", "Write Cloud-Optimized GeoTIFF with tiles and overviews").WithType(cli.TypeBool)).
+		WithAction(action(changeAction))
+
+	subsetCmd := cli.NewCommand("subset", "Crop an image to a pixel window or geographic extent").
+		WithArg(cli.NewArg("data", "Single- or multi-band image")).
+		WithOption(cli.NewOption("output", "Output directory (default: same as input)").WithChar('o')).
+		WithOption(cli.NewOption("window", "Pixel window x,y,width,height")).
+		WithOption(cli.NewOption("aoi", "Extent as the bounding box of a GeoJSON or other vector file, or .wkt file")).
+		WithOption(cli.NewOption("bbox", "Extent as bounding box west,south,east,north")).
+		WithOption(cli.NewOption("epsg", "EPSG code of the bounding box and of .wkt files (default: 4326)").WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("skip", "Skip existing").WithChar('s').WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("verbose", "Verbose mode").WithChar('v').WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("sidecar", "Write provenance also into a JSON sidecar").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("compress", "Compression profile: none, lzw, deflate, zstd")).
+		WithOption(cli.NewOption("overviews", "Build internal overviews").WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("cog", "Write Cloud-Optimized GeoTIFF with tiles and overviews").WithType(cli.TypeBool)).
+		WithAction(action(subsetAction))
+
+	runCmd := cli.NewCommand("run", "Run the stages of a pipeline skipping up-to-date products").
+		WithArg(cli.NewArg("pipeline", "Pipeline YAML file")).
+		WithOption(cli.NewOption("workers", "Tasks run in parallel (default: from pipeline or 1)").WithChar('w').WithType(cli.TypeInt)).
+		WithOption(cli.NewOption("verbose", "Verbose mode").WithChar('v').WithType(cli.TypeBool)).
+		WithAction(action(runAction))
+
+	vectorizeCmd := cli.NewCommand("vectorize", "Polygonise class, change or transitions maps").
+		WithArg(cli.NewArg("data", "Class map uni-band")).
+		WithOption(cli.NewOption("output", "Output directory (default: same as input)").WithChar('o')).
+		WithOption(cli.NewOption("format", "Vector format: geojson, gpkg, shp (default: gpkg)").WithChar('f')).
+		WithOption(cli.NewOption("sieve", "Sieve class maps first, e.g. sieve:10 or sieve:0.5ha:4")).
+		WithOption(cli.NewOption("simplify", "Simplification tolerance in projection units (default: none)").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("skip", "Skip existing").WithChar('s').WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("verbose", "Verbose mode").WithChar('v').WithType(cli.TypeBool)).
+		WithAction(action(vectorizeAction))
+
+	catalogCmd := cli.NewCommand("catalog", "Index the Landsat scenes of a directory tree into a JSON catalogue").
+		WithArg(cli.NewArg("root", "Directory tree of scene files and converted images (default: current)").AsOptional()).
+		WithOption(cli.NewOption("output", "Catalogue file (default: catalog.json in the root)").WithChar('o')).
+		WithOption(cli.NewOption("verbose", "List the scenes").WithChar('v').WithType(cli.TypeBool)).
+		WithOption(cli.NewOption("from", "Select scenes acquired on or after the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("to", "Select scenes acquired on or before the date YYYY-MM-DD")).
+		WithOption(cli.NewOption("doy", "Select scenes acquired within the day-of-year range, e.g. 170-240")).
+		WithOption(cli.NewOption("max-cloud", "Select scenes of at most the cloud cover in percent").WithType(cli.TypeNumber)).
+		WithOption(cli.NewOption("pathrow", "Select scenes of the WRS-2 path/rows, e.g. 188/012,190/011")).
+		WithAction(action(catalogAction))
 
 	app := cli.New("Normalize and classify Landsat images for the Northern hemisphere").
+		WithOption(cli.NewOption("log-level", "Log level: debug, info, warn, error (default: info)")).
+		WithOption(cli.NewOption("log-format", "Log format: text, json (default: text)")).
+		WithOption(cli.NewOption("progress", "Progress in verbose mode: bar, log, json, none (default: bar)")).
 		WithCommand(convertCmd).
 		WithCommand(trainingCmd).
 		WithCommand(predictCmd).
 		WithCommand(filterCmd).
 		WithCommand(trimCmd).
-		WithCommand(changeCmd)
+		WithCommand(changeCmd).
+		WithCommand(subsetCmd).
+		WithCommand(vectorizeCmd).
+		WithCommand(runCmd).
+		WithCommand(catalogCmd)
 
 	os.Exit(app.Run(os.Args, os.Stdout))
 }
 
-func convertAction(args []string, options map[string]string) int {
+func convertAction(args []string, options map[string]string) error {
 	var (
 		ok, skip, l1 bool
 		err          error
 		root         string
-		fNames       []string
 	)
 	if root, ok = options["input"]; !ok {
 		root, _ = os.Getwd()
 	}
-	if fNames, err = io.ScanTree(root, ".*_B1.TIF"); err != nil {
-		log.Fatal(err)
+	scenes, err := scanScenes(root)
+	if err != nil {
+		return err
 	}
 	if _, ok = options["l1"]; ok {
 		l1 = true
@@ -99,21 +245,91 @@ func convertAction(args []string, options map[string]string) int {
 	if _, ok = options["skip"]; ok {
 		skip = true
 	}
+	query, err := readQuery(options)
+	if err != nil {
+		return err
+	}
 	pathOut, verbose := parseOptions(root, options)
-	for _, fName := range fNames {
-		pathIn := path.Dir(fName)
-		pattern := strings.Replace(path.Base(fName), "_B1.TIF", "", 1)
+	_ = io.MkdirAll(pathOut)
+	for _, sc := range scenes {
+		pathIn, pattern := sc.pathIn, sc.prefix
+		fName := path.Join(pathIn, pattern)
+		if io.IsArchive(pathIn) {
+			fName = pathIn
+		}
+		if !query.Selects(fName) {
+			logging.Debug("not selected", "file", fName)
+			continue
+		}
 		if verbose {
-			log.Printf("Merging and correcting %s into %s\n", pathIn, pathOut)
+			logging.Info("merging and correcting", "input", pathIn, "output", pathOut)
 		}
-		if err := conversion.MergeAndApply(pathIn, pattern, pathOut, l1, skip, verbose, args...); err != nil {
-			log.Fatal(err)
+		fileOut := path.Join(pathOut, pattern+".tiff")
+		output := outputOptions(dataset.ReflectanceOutput(), options)
+		output.Extra = args
+		_, err = produce("convert", args, options, fileOut, skip, output, func(rec *provenance.Record, fileOut string) error {
+			sceneFNames := []string{pathIn}
+			if !io.IsArchive(pathIn) {
+				// the band and MTL files of the scene next to each other, not earlier products written there
+				id, err := product.ParseID(pattern)
+				if err != nil {
+					return err
+				}
+				sceneRe := `(?i)^` + regexp.QuoteMeta(id.String()) + `.*(_B\d+(_VCID_\d)?\.TIF|_MTL\.json)$`
+				if sceneFNames, err = io.Inputs(pathIn, sceneRe); err != nil {
+					return err
+				}
+			}
+			for _, sceneFName := range sceneFNames {
+				if err := rec.AddInput(sceneFName); err != nil {
+					return err
+				}
+			}
+			return conversion.MergeAndApply(pathIn, pattern, fileOut, l1, false, verbose, output)
+		})
+		if err != nil {
+			return err
 		}
 	}
-	return 0
+	return nil
+}
+
+// scene locates the band files of a product by their directory or tar archive and their common prefix.
+type scene struct {
+	pathIn, prefix string
 }
 
-func fieldDataAction(args []string, options map[string]string) int {
+// scanScenes finds the products of the directory tree by their first band, as files or within tar archives.
+// Bands are expected at the top level of archives as delivered by USGS.
+func scanScenes(root string) ([]scene, error) {
+	const band1 = "_B1.TIF"
+	var res []scene
+	fNames, err := io.ScanTree(root, ".*"+band1)
+	if err != nil {
+		return nil, err
+	}
+	for _, fName := range fNames {
+		res = append(res, scene{path.Dir(fName), strings.TrimSuffix(path.Base(fName), band1)})
+	}
+	archives, err := io.ScanTree(root, io.ArchivePattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, archive := range archives {
+		names, err := io.List(archive)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if strings.HasSuffix(name, band1) && !strings.Contains(name, "/") {
+				res = append(res, scene{archive, strings.TrimSuffix(name, band1)})
+			}
+		}
+	}
+	return res, nil
+}
+
+func fieldDataAction(args []string, options map[string]string) error {
 	var (
 		ok       bool
 		imageDir string
@@ -124,105 +340,821 @@ func fieldDataAction(args []string, options map[string]string) int {
 		imageDir = current
 	}
 	pathOut, _ := parseOptions(current, options)
-	if err := classification.CollectTrainingData(coordDir, imageDir, pathOut, ".*.tiff"); err != nil {
-		log.Fatal(err)
+	_ = io.MkdirAll(path.Dir(pathOut))
+	query, err := readQuery(options)
+	if err != nil {
+		return err
+	}
+	opts, err := readTrainingOptions(options)
+	if err != nil {
+		return err
+	}
+	if err = classification.CollectTrainingData(coordDir, imageDir, pathOut, ".*.tiff", query, opts); err != nil {
+		return err
+	}
+	return nil
+}
+
+// readTrainingOptions overrides the default training data output with the command line options.
+func readTrainingOptions(options map[string]string) (classification.TrainingOptions, error) {
+	res := classification.DefaultTrainingOptions()
+	var err error
+	if v, ok := options["format"]; ok {
+		switch res.Format = strings.ToLower(v); res.Format {
+		case data.FormatCSV, data.FormatParquet, data.FormatNPZ:
+		default:
+			return res, fmt.Errorf("unknown training data format %s, expected csv, parquet or npz", v)
+		}
+	}
+	if v, ok := options["split"]; ok {
+		res.Split.Strategy = v
+	}
+	if v, ok := options["balance"]; ok {
+		res.Balance.Strategy = v
+	}
+	for key, value := range map[string]*float64{"block-size": &res.Split.BlockSize, "train-fraction": &res.Split.TrainFraction, "jitter": &res.Balance.Jitter,
+		"outlier-quantile": &res.QC.Quantile, "boundary-margin": &res.QC.Margin, "sample-radius": &res.Sampling.Radius, "max-variance": &res.Sampling.MaxVariance} {
+		if v, ok := options[key]; ok {
+			if *value, err = strconv.ParseFloat(v, 64); err != nil {
+				return res, fmt.Errorf("invalid %s %s: %v", key, v, err)
+			}
+		}
+	}
+	for key, value := range map[string]*int{"folds": &res.Folds, "class-size": &res.Split.ClazzSize, "test-size": &res.Split.TestSize, "neighbours": &res.Balance.Neighbours,
+		"sample-window": &res.Sampling.Window} {
+		if v, ok := options[key]; ok {
+			if *value, err = strconv.Atoi(v); err != nil {
+				return res, fmt.Errorf("invalid %s %s: %v", key, v, err)
+			}
+		}
+	}
+	if v, ok := options["seed"]; ok {
+		if res.Split.Seed, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return res, fmt.Errorf("invalid seed %s: %v", v, err)
+		}
+	}
+	_, res.Sampling.Temporal = options["temporal"]
+	_, res.Inspect = options["qc"]
+	_, res.QC.Exclude = options["exclude-flagged"]
+	if err = res.Sampling.Validate(); err != nil {
+		return res, err
 	}
-	return 0
+	if err = res.Split.Validate(); err != nil {
+		return res, err
+	}
+	if err = res.QC.Validate(); err != nil {
+		return res, err
+	}
+	return res, res.Balance.Validate()
 }
 
-func predictAction(args []string, options map[string]string) int {
+func predictAction(args []string, options map[string]string) error {
 	var (
 		ok       bool
-		skip     bool
 		modelDir string
 	)
-	fileIn := args[0]
 	if modelDir, ok = options["model"]; !ok {
 		current, _ := os.Getwd()
 		modelDir = path.Join(current, "tf.model")
 	}
-	pathOut, verbose := parseOptions(path.Dir(fileIn), options)
-	if pathOut == path.Dir(fileIn) {
-		pathOut = path.Join(pathOut, "classification")
-	}
-	_ = os.MkdirAll(pathOut, 0750)
-	fileOut := path.Join(pathOut, path.Base(fileIn))
-	id := 7
+	id := 0
 	if idStr, ok := options["id"]; ok {
 		id, _ = strconv.Atoi(idStr)
 	}
-	if _, ok = options["skip"]; ok {
-		skip = true
+	_, skip := options["skip"]
+	_, verbose := options["verbose"]
+	output := outputOptions(dataset.ClassMapOutput(), options)
+
+	// the model is loaded once for all images, and not at all if they are up-to-date
+	var (
+		once     sync.Once
+		model    *classification.Model
+		modelErr error
+	)
+	defer func() {
+		if model != nil {
+			model.Close()
+		}
+	}()
+	if _, temporal := options["temporal"]; temporal {
+		inputs, err := selectInputs(args[0], options)
+		if err != nil {
+			return err
+		}
+		// a season is processed as one input named after its first image
+		seasons := make(map[string][]string)
+		var firsts []string
+		for _, season := range data.Seasons(inputs) {
+			seasons[season[0]] = season
+			firsts = append(firsts, season[0])
+		}
+		return batchInputs(firsts, options, func(fileIn string) (bool, error) {
+			season := seasons[fileIn]
+			fileOut := seasonOutput(fileIn, options)
+			_ = io.MkdirAll(path.Dir(fileOut))
+			r, err := dataset.OpenMultiBand(fileIn)
+			if err != nil {
+				return false, err
+			}
+			window, err := readWindow(r.ImageParams(), options)
+			r.Close()
+			if err != nil {
+				return false, err
+			}
+			return produce("predict", season, options, fileOut, skip, output, func(rec *provenance.Record, fileOut string) error {
+				for _, fileIn := range season {
+					if err := rec.AddInput(fileIn); err != nil {
+						return err
+					}
+				}
+				if err := rec.SetModel(modelDir); err != nil {
+					return err
+				}
+				rec.SetLegend(classification.Legend())
+				once.Do(func() { model, modelErr = classification.LoadModel(modelDir) })
+				if modelErr != nil {
+					return modelErr
+				}
+				return model.ClassifySeason(season, fileOut, window, verbose, output)
+			})
+		})
+	}
+	return batch(args[0], options, func(fileIn string) (bool, error) {
+		fileOut := predictOutput(fileIn, options)
+		_ = io.MkdirAll(path.Dir(fileOut))
+		r, err := dataset.OpenMultiBand(fileIn)
+		if err != nil {
+			return false, err
+		}
+		window, err := readWindow(r.ImageParams(), options)
+		r.Close()
+		if err != nil {
+			return false, err
+		}
+		return produce("predict", []string{fileIn}, options, fileOut, skip, output, func(rec *provenance.Record, fileOut string) error {
+			if err := rec.AddInput(fileIn); err != nil {
+				return err
+			}
+			if err := rec.SetModel(modelDir); err != nil {
+				return err
+			}
+			rec.SetLegend(classification.Legend())
+			once.Do(func() { model, modelErr = classification.LoadModel(modelDir) })
+			if modelErr != nil {
+				return modelErr
+			}
+			return model.Classify(fileIn, fileOut, window, id, verbose, output)
+		})
+	})
+}
+
+func filterAction(args []string, options map[string]string) error {
+	spec := args[0]
+	_, skip := options["skip"]
+	_, verbose := options["verbose"]
+	output := outputOptions(dataset.ClassMapOutput(), options)
+	var process func(fileIn, fileOut string) error
+	if strings.HasPrefix(spec, "sieve") {
+		minSize, connectivity, err := filter.ParseSieve(spec)
+		if err != nil {
+			return err
+		}
+		process = func(fileIn, fileOut string) error {
+			return filter.Sieve(fileIn, fileOut, minSize, connectivity, false, verbose, output)
+		}
+	} else {
+		kernel, err := filter.ParseKernel(spec)
+		if err != nil {
+			return err
+		}
+		process = func(fileIn, fileOut string) error {
+			return filter.Modal(fileIn, fileOut, kernel, false, verbose, output)
+		}
 	}
-	if err := classification.Predict(modelDir, fileIn, fileOut, 0, 9000, 0, 9000, id, skip, verbose); err != nil {
-		log.Fatal(err)
+	return batch(args[1], options, func(fileIn string) (bool, error) {
+		fileOut := filterOutput(spec, fileIn, options)
+		_ = io.MkdirAll(path.Dir(fileOut))
+		return produce("filter", []string{spec, fileIn}, options, fileOut, skip, output, func(rec *provenance.Record, fileOut string) error {
+			if err := rec.AddInput(fileIn); err != nil {
+				return err
+			}
+			rec.SetLegend(classification.Legend())
+			return process(fileIn, fileOut)
+		})
+	})
+}
+
+func trimAction(args []string, options map[string]string) error {
+	_, skip := options["skip"]
+	_, verbose := options["verbose"]
+	_, outside := options["outside"]
+	_, crop := options["crop"]
+	opts := trim.Options{Outside: outside, Crop: crop}
+	return batch(args[0], options, func(fileIn string) (bool, error) {
+		fileOut := trimOutput(fileIn, options)
+		_ = io.MkdirAll(path.Dir(fileOut))
+		r, err := dataset.OpenMultiBand(fileIn)
+		if err != nil {
+			return false, err
+		}
+		defaults, classMap := defaultOutput(r)
+		aoi, err := readRegion(r.ImageParams().Projection(), options)
+		r.Close()
+		if err != nil {
+			return false, err
+		}
+		output := outputOptions(defaults, options)
+		return produce("trim", []string{fileIn}, options, fileOut, skip, output, func(rec *provenance.Record, fileOut string) error {
+			if err := rec.AddInput(fileIn); err != nil {
+				return err
+			}
+			if fileAOI, ok := options["aoi"]; ok {
+				if err := rec.AddInput(fileAOI); err != nil {
+					return err
+				}
+			}
+			if classMap {
+				rec.SetLegend(classification.Legend())
+			}
+			return trim.Process(fileIn, fileOut, aoi, opts, false, verbose, output)
+		})
+	})
+}
+
+// batch runs the processing of every image of the input, a file, a directory or a glob pattern, with the number
+// of workers of the workers option. It continues past failures and logs the outcome per image before failing
+// if any did.
+func batch(input string, options map[string]string, process func(fileIn string) (skipped bool, err error)) error {
+	inputs, err := selectInputs(input, options)
+	if err != nil {
+		return err
 	}
-	return 0
+	return batchInputs(inputs, options, process)
 }
 
-func filterAction(args []string, options map[string]string) int {
+// selectInputs lists the images of the input selected by the scene selection options.
+func selectInputs(input string, options map[string]string) ([]string, error) {
+	inputs, err := io.Inputs(input, tiffPattern)
+	if err != nil {
+		return nil, err
+	}
+	query, err := readQuery(options)
+	if err != nil {
+		return nil, err
+	}
+	if !query.Empty() {
+		var selected []string
+		for _, fileIn := range inputs {
+			if query.Selects(fileIn) {
+				selected = append(selected, fileIn)
+			}
+		}
+		logging.Info("scenes selected", "selected", len(selected), "of", len(inputs))
+		if len(selected) == 0 {
+			return nil, fmt.Errorf("none of %d images of %s selected", len(inputs), input)
+		}
+		inputs = selected
+	}
+	return inputs, nil
+}
+
+// batchInputs runs the processing of the inputs as batch does.
+func batchInputs(inputs []string, options map[string]string, process func(fileIn string) (skipped bool, err error)) error {
+	var err error
+	workers := 1
+	if v, ok := options["workers"]; ok {
+		if workers, err = strconv.Atoi(v); err != nil || workers < 1 {
+			return fmt.Errorf("workers must be a positive number, found %s", v)
+		}
+	}
+
+	type outcome struct {
+		skipped bool
+		err     error
+	}
+	res := make([]outcome, len(inputs))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, fileIn := range inputs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, fileIn string) {
+			defer func() { <-sem; wg.Done() }()
+			res[i].skipped, res[i].err = process(fileIn)
+		}(i, fileIn)
+	}
+	wg.Wait()
+
+	var done, skipped, failed int
+	for i, o := range res {
+		switch {
+		case o.err != nil:
+			failed++
+			logging.Error("failed", "file", inputs[i], "error", o.err)
+		case o.skipped:
+			skipped++
+			logging.Info("up-to-date", "file", inputs[i])
+		default:
+			done++
+			logging.Info("done", "file", inputs[i])
+		}
+	}
+	if len(inputs) > 1 {
+		logging.Info("summary", "done", done, "up-to-date", skipped, "failed", failed)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d file(s) failed", failed, len(inputs))
+	}
+	return nil
+}
+
+func subsetAction(args []string, options map[string]string) error {
 	var (
 		ok   bool
 		skip bool
 	)
-	algo := args[0]
-	fileIn := args[1]
-	pathOut, verbose := parseOptions(path.Dir(fileIn), options)
-	pathOut = path.Join(pathOut, algo)
-	_ = os.MkdirAll(pathOut, 0750)
+	fileIn := args[0]
+	_, verbose := parseOptions(path.Dir(fileIn), options)
+	fileOut := subsetOutput(fileIn, options)
+	_ = io.MkdirAll(path.Dir(fileOut))
 
-	fileOut := path.Join(pathOut, path.Base(fileIn))
 	if _, ok = options["skip"]; ok {
 		skip = true
 	}
-	switch algo {
-	case "3x3":
-		if err := filter.Filter3x3(fileIn, fileOut, skip, verbose); err != nil {
-			log.Fatal(err)
+	r, err := dataset.OpenMultiBand(fileIn)
+	if err != nil {
+		return err
+	}
+	defaults, classMap := defaultOutput(r)
+	window, err := readWindow(r.ImageParams(), options)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	output := outputOptions(defaults, options)
+	_, err = produce("subset", args, options, fileOut, skip, output, func(rec *provenance.Record, fileOut string) error {
+		if err := rec.AddInput(fileIn); err != nil {
+			return err
+		}
+		if fileAOI, ok := options["aoi"]; ok {
+			if err := rec.AddInput(fileAOI); err != nil {
+				return err
+			}
+		}
+		if classMap {
+			rec.SetLegend(classification.Legend())
+		}
+		return subset.Process(fileIn, fileOut, window, false, verbose, output)
+	})
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// defaultOutput returns the default output options of class maps, single Byte bands, or of reflectance images.
+func defaultOutput(r dataset.MultiBandReader) (dataset.OutputOptions, bool) {
+	if r.Bands() == 1 && r.ImageParams().DataType() == gdal.Byte {
+		return dataset.ClassMapOutput(), true
+	}
+	return dataset.ReflectanceOutput(), false
+}
+
+// readWindow reads the pixel window from the window option, or as the bounding box of the aoi or bbox options;
+// the full image without either.
+func readWindow(ip *dataset.ImageParams, options map[string]string) (dataset.Box, error) {
+	if window, ok := options["window"]; ok {
+		var res dataset.Box
+		parts := strings.Split(window, ",")
+		if len(parts) != len(res) {
+			return res, fmt.Errorf("window %s must be x,y,width,height", window)
+		}
+		for i, part := range parts {
+			var err error
+			if res[i], err = strconv.Atoi(strings.TrimSpace(part)); err != nil {
+				return res, err
+			}
+		}
+		return res, nil
+	}
+	_, aoi := options["aoi"]
+	_, bbox := options["bbox"]
+	if !aoi && !bbox {
+		return dataset.Box{0, 0, ip.XSize(), ip.YSize()}, nil
+	}
+	region, err := readRegion(ip.Projection(), options)
+	if err != nil {
+		return dataset.Box{}, err
+	}
+	return region.Window(ip), nil
+}
+
+// readQuery reads the scene selection from the from, to, doy, max-cloud and pathrow options.
+func readQuery(options map[string]string) (*catalog.Query, error) {
+	res := &catalog.Query{}
+	var err error
+	for key, date := range map[string]*time.Time{"from": &res.From, "to": &res.To} {
+		if v, ok := options[key]; ok {
+			if *date, err = time.Parse("2006-01-02", v); err != nil {
+				return nil, fmt.Errorf("%s date %s must be YYYY-MM-DD", key, v)
+			}
+		}
+	}
+	if v, ok := options["doy"]; ok {
+		parts := strings.Split(v, "-")
+		if len(parts) == 2 {
+			res.FirstDay, err = strconv.Atoi(parts[0])
+			if err == nil {
+				res.LastDay, err = strconv.Atoi(parts[1])
+			}
+		}
+		if len(parts) != 2 || err != nil || res.FirstDay < 1 || res.LastDay < 1 || res.FirstDay > 366 || res.LastDay > 366 {
+			return nil, fmt.Errorf("day-of-year range %s must be first-last, e.g. 170-240", v)
+		}
+	}
+	if v, ok := options["max-cloud"]; ok {
+		maxCloud, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, err
+		}
+		res.MaxCloud = &maxCloud
+	}
+	if v, ok := options["pathrow"]; ok {
+		for _, part := range strings.Split(v, ",") {
+			pr, err := catalog.ParsePathRow(part)
+			if err != nil {
+				return nil, err
+			}
+			res.PathRows = append(res.PathRows, pr)
+		}
+	}
+	return res, nil
+}
+
+// readRegion reads the area of interest from the aoi or bbox options in the projection of the images.
+func readRegion(projection string, options map[string]string) (*trim.AOI, error) {
+	epsg := 4326
+	if v, ok := options["epsg"]; ok {
+		var err error
+		if epsg, err = strconv.Atoi(v); err != nil {
+			return nil, err
+		}
+	}
+	if fileAOI, ok := options["aoi"]; ok {
+		return trim.ReadAOI(fileAOI, epsg, projection)
+	}
+	bbox, ok := options["bbox"]
+	if !ok {
+		return nil, fmt.Errorf("either an aoi file or a bbox is required")
+	}
+	var bounds []float64
+	for _, v := range strings.Split(bbox, ",") {
+		b, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return nil, err
+		}
+		bounds = append(bounds, b)
+	}
+	if len(bounds) != 4 {
+		return nil, fmt.Errorf("bbox %s must be west,south,east,north", bbox)
+	}
+	return trim.BBox(bounds[0], bounds[1], bounds[2], bounds[3], epsg, projection)
+}
+
+func changeAction(args []string, options map[string]string) error {
+	fromTiffs := strings.Split(args[0], ",")
+	toTiffs := strings.Split(args[1], ",")
+	pathOut, ok := options["output"]
+	if !ok {
+		pathOut, _ = os.Getwd()
+	}
+	_, verbose := options["verbose"]
+	fileOut := path.Join(pathOut, change.OutputName)
+	r, err := dataset.OpenUniBand(fromTiffs[0])
+	if err != nil {
+		return err
+	}
+	region, err := readRegion(r.ImageParams().Projection(), options)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	tl, br := region.Bounds()
+	output := outputOptions(dataset.ClassMapOutput(), options)
+	transitions := path.Join(pathOut, change.TransitionsName)
+	_, err = produce("change", args, options, fileOut, false, output, func(rec *provenance.Record, fileOut string) error {
+		for _, fileIn := range append(append([]string{}, fromTiffs...), toTiffs...) {
+			if err := rec.AddInput(fileIn); err != nil {
+				return err
+			}
+		}
+		if fileAOI, ok := options["aoi"]; ok {
+			if err := rec.AddInput(fileAOI); err != nil {
+				return err
+			}
+		}
+		rec.SetLegend(change.Legend())
+		local := io.Staging(transitions)
+		partial := dataset.PartialName(local)
+		if err := change.Collect(fromTiffs, toTiffs, tl, br, fileOut, partial, verbose, output); err != nil {
+			return err
 		}
-	case "5x5":
-		if err := filter.Filter5x5(fileIn, fileOut, skip, verbose); err != nil {
-			log.Fatal(err)
+		// the transitions map shares the provenance of the change map
+		trec := rec.Copy()
+		if err := complete(trec, partial, output); err != nil {
+			return err
 		}
-	default:
-		log.Fatal("unknown algorithm")
+		if err := dataset.Commit(local); err != nil {
+			return err
+		}
+		if err := publish(local, transitions); err != nil {
+			return err
+		}
+		if _, sidecar := options["sidecar"]; sidecar {
+			return trec.WriteSidecar(transitions)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
+	return nil
 
-	return 0
 }
 
-func trimAction(args []string, options map[string]string) int {
+func vectorizeAction(args []string, options map[string]string) error {
 	var (
-		ok   bool
-		skip bool
+		ok       bool
+		skip     bool
+		simplify float64
+		err      error
 	)
 	fileIn := args[0]
-	pathOut, verbose := parseOptions(path.Dir(fileIn), options)
-	pathOut = path.Join(pathOut, "trimmed")
-	_ = os.MkdirAll(pathOut, 0750)
+	_, verbose := parseOptions(path.Dir(fileIn), options)
+	fileOut := vectorizeOutput(fileIn, options)
+	_ = io.MkdirAll(path.Dir(fileOut))
 
-	fileOut := path.Join(pathOut, path.Base(fileIn))
 	if _, ok = options["skip"]; ok {
 		skip = true
 	}
-	if err := trim.Process(fileIn, fileOut, skip, verbose, trim.TL, trim.TR, trim.BR, trim.BL); err != nil {
-		log.Fatal(err)
+	if v, ok := options["simplify"]; ok {
+		if simplify, err = strconv.ParseFloat(v, 64); err != nil {
+			return err
+		}
+	}
+
+	r, err := dataset.OpenUniBand(fileIn)
+	if err != nil {
+		return err
+	}
+	transitions := change.IsTransitions(r.DatasetParams())
+	categories := r.RasterParams().Categories()
+	r.Close()
+
+	var fields []vector.Field
+	if transitions {
+		fields = vector.TransitionFields(classification.Legend(), change.Decode)
+	} else {
+		legend := classification.Legend()
+		if categories != nil {
+			legend = make(map[int]string)
+			for v, c := range categories {
+				legend[v] = c.Name
+			}
+		}
+		fields = vector.ClassFields(legend)
+	}
+
+	if spec, ok := options["sieve"]; ok {
+		if transitions {
+			return fmt.Errorf("sieving is only supported for class maps")
+		}
+		minSize, connectivity, err := filter.ParseSieve(spec)
+		if err != nil {
+			return err
+		}
+		tmpDir, err := os.MkdirTemp("", "vectorize")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(tmpDir)
+		sieved := path.Join(tmpDir, path.Base(fileIn))
+		if err = filter.Sieve(fileIn, sieved, minSize, connectivity, false, verbose, dataset.ClassMapOutput()); err != nil {
+			return err
+		}
+		fileIn = sieved
+	}
+
+	local := io.Staging(fileOut)
+	if err = io.MkdirAll(path.Dir(local)); err != nil {
+		return err
+	}
+	if err = vector.Polygonize(fileIn, local, fields, simplify, skip, verbose); err != nil {
+		return err
 	}
-	return 0
+	return publish(local, fileOut)
 }
 
-func changeAction(args []string, options map[string]string) int {
-	fromTiffs := strings.Split(args[0], ",")
-	toTiffs := strings.Split(args[1], ",")
-	output, ok := options["output"]
+func catalogAction(args []string, options map[string]string) error {
+	root, _ := os.Getwd()
+	if len(args) > 0 {
+		root = args[0]
+	}
+	fileOut, ok := options["output"]
+	if !ok {
+		fileOut = path.Join(root, "catalog.json")
+	}
+	query, err := readQuery(options)
+	if err != nil {
+		return err
+	}
+	c, err := catalog.Build(root)
+	if err != nil {
+		return err
+	}
+	c.Scenes = c.Select(query)
+	if _, verbose := options["verbose"]; verbose {
+		for _, s := range c.Scenes {
+			kv := []interface{}{"id", s.ID, "acquired", s.Acquired.Format("2006-01-02"), "bands", len(s.Bands),
+				"images", len(s.Images)}
+			if s.CloudCover != nil {
+				kv = append(kv, "cloud_cover", *s.CloudCover)
+			}
+			logging.Info("scene", kv...)
+		}
+	}
+	if err = c.Save(fileOut); err != nil {
+		return err
+	}
+	logging.Info("catalogue written", "scenes", len(c.Scenes), "file", fileOut)
+	return nil
+}
+
+// predictOutput returns the class map of predict, by default in the classification directory next to the input.
+func predictOutput(fileIn string, options map[string]string) string {
+	pathOut, _ := parseOptions(path.Dir(fileIn), options)
+	if pathOut == path.Dir(fileIn) {
+		pathOut = path.Join(pathOut, "classification")
+	}
+	return path.Join(pathOut, path.Base(fileIn))
+}
+
+// seasonOutput returns the class map of the season of the image, named after it with a _season suffix.
+func seasonOutput(fileIn string, options map[string]string) string {
+	fileOut := predictOutput(fileIn, options)
+	ext := path.Ext(fileOut)
+	return strings.TrimSuffix(fileOut, ext) + "_season" + ext
+}
+
+// filterOutput returns the filtered class map in a directory named after the filter specification.
+func filterOutput(spec, fileIn string, options map[string]string) string {
+	pathOut, _ := parseOptions(path.Dir(fileIn), options)
+	pathOut = path.Join(pathOut, strings.NewReplacer(":", "-", ",", "-", "=", "").Replace(spec))
+	return path.Join(pathOut, path.Base(fileIn))
+}
+
+func trimOutput(fileIn string, options map[string]string) string {
+	pathOut, _ := parseOptions(path.Dir(fileIn), options)
+	return path.Join(pathOut, "trimmed", path.Base(fileIn))
+}
+
+func subsetOutput(fileIn string, options map[string]string) string {
+	pathOut, _ := parseOptions(path.Dir(fileIn), options)
+	return path.Join(pathOut, "subset", path.Base(fileIn))
+}
+
+func vectorizeOutput(fileIn string, options map[string]string) string {
+	pathOut, _ := parseOptions(path.Dir(fileIn), options)
+	format, ok := options["format"]
 	if !ok {
-		output, _ = os.Getwd()
+		format = "gpkg"
+	}
+	return path.Join(pathOut, strings.TrimSuffix(path.Base(fileIn), path.Ext(fileIn))+"."+format)
+}
+
+// products lists the products of the commands run per input file or, for change, once by pipelines.
+var products = map[string]pipeline.Products{
+	"predict": func(input string, _ []string, options map[string]string) []string {
+		// seasons are predicted by stages run once with outputs globs, see pipeline.Load
+		if v, temporal := options["temporal"]; temporal && v != "false" {
+			return []string{seasonOutput(input, options)}
+		}
+		return []string{predictOutput(input, options)}
+	},
+	"filter": func(input string, args []string, options map[string]string) []string {
+		if len(args) == 0 {
+			return nil
+		}
+		return []string{filterOutput(args[0], input, options)}
+	},
+	"trim": func(input string, _ []string, options map[string]string) []string {
+		return []string{trimOutput(input, options)}
+	},
+	"subset": func(input string, _ []string, options map[string]string) []string {
+		return []string{subsetOutput(input, options)}
+	},
+	"vectorize": func(input string, _ []string, options map[string]string) []string {
+		return []string{vectorizeOutput(input, options)}
+	},
+	"change": func(_ string, _ []string, options map[string]string) []string {
+		// relative to the working directory of the pipeline by default
+		pathOut := options["output"]
+		return []string{path.Join(pathOut, change.OutputName), path.Join(pathOut, change.TransitionsName)}
+	},
+}
+
+func runAction(args []string, options map[string]string) error {
+	_, verbose := options["verbose"]
+	p, err := pipeline.Load(args[0])
+	if err != nil {
+		return err
 	}
-	if err := change.Collect(fromTiffs, toTiffs, change.TL, change.BR, output); err != nil {
-		log.Fatal(err)
+	if workers, ok := options["workers"]; ok {
+		if p.Workers, err = strconv.Atoi(workers); err != nil || p.Workers < 1 {
+			return fmt.Errorf("workers must be a positive number, found %s", workers)
+		}
+	}
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	runner, err := pipeline.NewRunner(p, products, executable, verbose)
+	if err != nil {
+		return err
+	}
+	report, err := runner.Run()
+	if report != nil {
+		counts := make(map[string]int)
+		for _, t := range report.Tasks {
+			counts[t.Status]++
+		}
+		logging.Info("pipeline finished", "done", counts[pipeline.StatusDone], "up-to-date",
+			counts[pipeline.StatusSkipped], "failed", counts[pipeline.StatusFailed], "report", p.Report)
+	}
+	if err != nil {
+		return err
 	}
-	return 0
+	return nil
+}
 
+// action adapts a command to the command line: it configures logging from the global options and logs the
+// error the command fails with instead of exiting, so that deferred clean-ups run.
+func action(command func(args []string, options map[string]string) error) cli.Action {
+	return func(args []string, options map[string]string) int {
+		if err := configureLogging(options); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		args, options = storagePaths(args, options)
+		if err := command(args, options); err != nil {
+			logging.Error("failed", "error", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// storagePaths translates the s3:// and http(s):// URLs of arguments, also in comma-separated lists, and of
+// path options into the GDAL virtual file system paths all commands operate on.
+func storagePaths(args []string, options map[string]string) ([]string, map[string]string) {
+	translate := func(v string) string {
+		parts := strings.Split(v, ",")
+		for i, part := range parts {
+			parts[i] = io.Path(part)
+		}
+		return strings.Join(parts, ",")
+	}
+	resArgs := make([]string, len(args))
+	for i, arg := range args {
+		resArgs[i] = translate(arg)
+	}
+	resOptions := make(map[string]string)
+	for k, v := range options {
+		switch k {
+		case "input", "output", "aoi":
+			v = translate(v)
+		}
+		resOptions[k] = v
+	}
+	return resArgs, resOptions
+}
+
+func configureLogging(options map[string]string) error {
+	var opts logging.Options
+	if level, ok := options["log-level"]; ok {
+		var err error
+		if opts.Level, err = logging.ParseLevel(level); err != nil {
+			return err
+		}
+	}
+	opts.Format = options["log-format"]
+	opts.Progress = options["progress"]
+	l, err := logging.New(os.Stderr, opts)
+	if err != nil {
+		return err
+	}
+	logging.SetDefault(l)
+	return nil
 }
 
 func parseOptions(root string, options map[string]string) (string, bool) {
@@ -238,3 +1170,111 @@ func parseOptions(root string, options map[string]string) (string, bool) {
 	}
 	return pathOut, verbose
 }
+
+// produce runs the processing of a single product unless it is up-to-date and skipping is requested, which it
+// reports. The process writes the product under the temporary name it is given and completes the provenance
+// record with its inputs. The record is then attached to the product, which is marked complete, finalized
+// according to the output options and renamed to its final name. Remote products are staged locally and
+// uploaded once complete.
+func produce(command string, args []string, options map[string]string, fileOut string, skip bool, output dataset.OutputOptions, process func(rec *provenance.Record, fileOut string) error) (skipped bool, err error) {
+	if skip && upToDate(fileOut) {
+		return true, nil
+	}
+	local := io.Staging(fileOut)
+	if err = io.MkdirAll(path.Dir(local)); err != nil {
+		return false, err
+	}
+	partial := dataset.PartialName(local)
+	rec := provenance.New(command, args, options)
+	if err = process(rec, partial); err != nil {
+		return false, err
+	}
+	if err = complete(rec, partial, output); err != nil {
+		return false, err
+	}
+	if err = dataset.Commit(local); err != nil {
+		return false, err
+	}
+	if err = publish(local, fileOut); err != nil {
+		return false, err
+	}
+	if _, sidecar := options["sidecar"]; sidecar {
+		return false, rec.WriteSidecar(fileOut)
+	}
+	return false, nil
+}
+
+// publish uploads a product staged locally for remote storage together with the auxiliary files sharing its
+// name, such as .aux.xml or the other files of a shapefile, and removes the staged files. The product goes
+// last so that it is not found complete before its auxiliary files. Local products are left in place.
+func publish(local, fileOut string) error {
+	if local == fileOut {
+		return nil
+	}
+	entries, err := os.ReadDir(path.Dir(local))
+	if err != nil {
+		return err
+	}
+	base := path.Base(local)
+	stem := strings.TrimSuffix(base, path.Ext(base)) + "."
+	var names []string
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), stem) && entry.Name() != base {
+			names = append(names, entry.Name())
+		}
+	}
+	names = append(names, base)
+	for _, name := range names {
+		if err = io.Upload(path.Join(path.Dir(local), name), path.Join(path.Dir(fileOut), name)); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		_ = os.Remove(path.Join(path.Dir(local), name))
+	}
+	return nil
+}
+
+// complete attaches the provenance record to a product written under its temporary name, marks it complete
+// and finalizes it.
+func complete(rec *provenance.Record, partial string, output dataset.OutputOptions) error {
+	if err := rec.Attach(partial, false); err != nil {
+		return err
+	}
+	if err := dataset.MarkComplete(partial); err != nil {
+		return err
+	}
+	return dataset.Finalize(partial, output)
+}
+
+// upToDate tells whether the product is complete and none of the inputs in its provenance record was modified
+// since.
+func upToDate(fileOut string) bool {
+	if _, ok := dataset.Completed(fileOut); !ok {
+		return false
+	}
+	rec, err := provenance.Read(fileOut)
+	if err != nil {
+		return false
+	}
+	var inputs []string
+	if rec != nil {
+		inputs = rec.Paths()
+	}
+	return dataset.UpToDate(fileOut, inputs...)
+}
+
+// outputOptions overrides the command defaults with the output options given on the command line.
+func outputOptions(defaults dataset.OutputOptions, options map[string]string) dataset.OutputOptions {
+	res := defaults
+	if compress, ok := options["compress"]; ok {
+		res.Compression = strings.ToUpper(compress)
+	}
+	if _, ok := options["overviews"]; ok {
+		res.Overviews = true
+	}
+	if _, ok := options["cog"]; ok {
+		res.COG = true
+	}
+	return res
+}
@@ -0,0 +1,198 @@
+package pipeline
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Exec is the pseudo-command of stages running an external program, e.g. the Python model training, given by
+// the stage arguments.
+const Exec = "exec"
+
+// Pipeline declares the stages of a processing run. Relative paths are relative to the pipeline file.
+type Pipeline struct {
+	// Workers is the number of tasks of a stage run in parallel (default: 1).
+	Workers int `yaml:"workers"`
+	// Report is the file of the run report (default: the pipeline file with the .report.json extension).
+	Report string `yaml:"report"`
+	// State is the file keeping the fingerprints of products (default: the pipeline file with the .state.json
+	// extension).
+	State  string  `yaml:"state"`
+	Stages []Stage `yaml:"stages"`
+
+	dir string
+}
+
+// Stage runs a landsat command either once or once per input file.
+type Stage struct {
+	Name    string `yaml:"name"`
+	Command string `yaml:"command"`
+	// Args precede the input file on the command line, e.g. the filter kernel.
+	Args []string `yaml:"args"`
+	// Options are given on the command line as --key=value, boolean ones as --key for true.
+	Options map[string]string `yaml:"options"`
+	// Inputs are globs of files processed by a task each.
+	Inputs []string `yaml:"inputs"`
+	// From lists the stages whose products are processed by a task each.
+	From []string `yaml:"from"`
+	// After lists further stages to complete first, e.g. those producing files named in args or options.
+	After []string `yaml:"after"`
+	// Outputs are globs of the products of stages without known product names, e.g. convert and exec.
+	Outputs []string `yaml:"outputs"`
+}
+
+// Load reads and validates a pipeline.
+func Load(fileName string) (*Pipeline, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	p := &Pipeline{Workers: 1}
+	if err = dec.Decode(p); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", fileName, err)
+	}
+	if p.dir, err = filepath.Abs(filepath.Dir(fileName)); err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(filepath.Base(fileName), filepath.Ext(fileName))
+	if p.Report == "" {
+		p.Report = base + ".report.json"
+	}
+	if p.State == "" {
+		p.State = base + ".state.json"
+	}
+	if p.Workers < 1 {
+		return nil, fmt.Errorf("workers must be positive, found %d", p.Workers)
+	}
+	if _, err = p.order(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// PerInput tells whether the stage runs a task per input file rather than once.
+func (s *Stage) PerInput() bool {
+	return len(s.Inputs) > 0 || len(s.From) > 0
+}
+
+func (s *Stage) dependencies() []string {
+	return append(append([]string{}, s.From...), s.After...)
+}
+
+// order returns the stages so that every stage follows the stages it depends on, otherwise keeping the
+// declaration order.
+func (p *Pipeline) order() ([]*Stage, error) {
+	byName := make(map[string]*Stage)
+	for i := range p.Stages {
+		s := &p.Stages[i]
+		if s.Name == "" || s.Command == "" {
+			return nil, fmt.Errorf("stage %d needs a name and a command", i+1)
+		}
+		if _, ok := byName[s.Name]; ok {
+			return nil, fmt.Errorf("duplicate stage %s", s.Name)
+		}
+		if len(s.Inputs) > 0 && len(s.From) > 0 {
+			return nil, fmt.Errorf("stage %s takes its inputs either from globs or from stages", s.Name)
+		}
+		// a season is all scenes of a path/row and year, which a task on a single input would not see
+		if v, ok := s.Options["temporal"]; ok && v != "false" && s.Command == "predict" && (s.PerInput() || len(s.Outputs) == 0) {
+			return nil, fmt.Errorf("stage %s predicts seasons, it must run once on the image directory with outputs", s.Name)
+		}
+		byName[s.Name] = s
+	}
+	for _, s := range byName {
+		for _, dep := range s.dependencies() {
+			if _, ok := byName[dep]; !ok {
+				return nil, fmt.Errorf("stage %s depends on unknown stage %s", s.Name, dep)
+			}
+		}
+	}
+
+	var res []*Stage
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[string]int)
+	var visit func(s *Stage, path []string) error
+	visit = func(s *Stage, path []string) error {
+		switch state[s.Name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("stages depend on each other: %s", strings.Join(append(path, s.Name), " -> "))
+		}
+		state[s.Name] = visiting
+		for _, dep := range s.dependencies() {
+			if err := visit(byName[dep], append(path, s.Name)); err != nil {
+				return err
+			}
+		}
+		state[s.Name] = done
+		res = append(res, s)
+		return nil
+	}
+	for i := range p.Stages {
+		if err := visit(&p.Stages[i], nil); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// abs resolves a path relative to the pipeline file.
+func (p *Pipeline) abs(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(p.dir, name)
+}
+
+// glob returns the sorted files matching the globs relative to the pipeline file.
+func (p *Pipeline) glob(patterns []string) ([]string, error) {
+	var res []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(p.abs(pattern))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, matches...)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// commandLine returns the arguments of the landsat command of the stage on the input, if any.
+func (s *Stage) commandLine(input string) []string {
+	if s.Command == Exec {
+		return append([]string{}, s.Args...)
+	}
+	res := []string{s.Command}
+	var keys []string
+	for k := range s.Options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		switch v := s.Options[k]; v {
+		case "true":
+			res = append(res, "--"+k)
+		case "false":
+		default:
+			res = append(res, "--"+k+"="+v)
+		}
+	}
+	res = append(res, s.Args...)
+	if input != "" {
+		res = append(res, input)
+	}
+	return res
+}
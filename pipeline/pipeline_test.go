@@ -0,0 +1,155 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func load(t *testing.T, yml string) (*Pipeline, error) {
+	fileName := filepath.Join(t.TempDir(), "pipeline.yaml")
+	if err := os.WriteFile(fileName, []byte(yml), 0640); err != nil {
+		t.Fatal(err)
+	}
+	return Load(fileName)
+}
+
+func TestLoad(t *testing.T) {
+	p, err := load(t, `
+workers: 4
+stages:
+  - name: filter
+    command: filter
+    args: [5x5]
+    from: [trim]
+    options: {output: results, verbose: true, skip: false}
+  - name: trim
+    command: trim
+    from: [predict]
+    options: {output: results, epsg: 32636, aoi: study-area.wkt}
+  - name: predict
+    command: predict
+    inputs: [converted/*.tiff]
+    after: [training]
+  - name: training
+    command: exec
+    args: [python, classification/train_save_model.py]
+    outputs: [tf.model]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Workers != 4 || p.Report != "pipeline.report.json" || p.State != "pipeline.state.json" {
+		t.Errorf("unexpected settings %d, %s, %s", p.Workers, p.Report, p.State)
+	}
+	stages, _ := p.order()
+	var names []string
+	for _, s := range stages {
+		names = append(names, s.Name)
+	}
+	if expected := []string{"training", "predict", "trim", "filter"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected order %v, found %v", expected, names)
+	}
+	if actual, expected := stages[2].commandLine("a.tiff"),
+		[]string{"trim", "--aoi=study-area.wkt", "--epsg=32636", "--output=results", "a.tiff"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, found %v", expected, actual)
+	}
+	if actual, expected := stages[3].commandLine("a.tiff"),
+		[]string{"filter", "--output=results", "--verbose", "5x5", "a.tiff"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, found %v", expected, actual)
+	}
+	if actual, expected := stages[0].commandLine(""),
+		[]string{"python", "classification/train_save_model.py"}; !reflect.DeepEqual(actual, expected) {
+		t.Errorf("expected %v, found %v", expected, actual)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		yml, expected string
+	}{
+		{"stages:\n  - {name: a, command: trim, from: [b]}\n  - {name: b, command: trim, from: [a]}\n", "depend on each other"},
+		{"stages:\n  - {name: a, command: trim, from: [c]}\n", "unknown stage c"},
+		{"stages:\n  - {name: a, command: trim}\n  - {name: a, command: filter}\n", "duplicate stage a"},
+		{"stages:\n  - {name: a, command: trim, input: [x]}\n", "field input not found"},
+		{"stages:\n  - {name: a, command: predict, inputs: [x], options: {temporal: true}}\n", "must run once"},
+		{"stages:\n  - {name: a, command: predict, args: [x], options: {temporal: true}}\n", "must run once"},
+	}
+	for _, tt := range tests {
+		if _, err := load(t, tt.yml); err == nil || !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("expected error with %q, found %v", tt.expected, err)
+		}
+	}
+}
+
+func TestRunSkipsUpToDate(t *testing.T) {
+	p, err := load(t, `
+stages:
+  - name: write
+    command: exec
+    args: [sh, -c, "echo a >> out.txt"]
+    outputs: [out.txt]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var statuses []string
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			// changing the product invalidates it
+			if err = os.WriteFile(p.abs("out.txt"), []byte("b\n"), 0640); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r, err := NewRunner(p, nil, "", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		report, err := r.Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+		statuses = append(statuses, report.Tasks[0].Status)
+	}
+	if expected := []string{StatusDone, StatusSkipped, StatusDone}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("expected %v, found %v", expected, statuses)
+	}
+	if data, _ := os.ReadFile(p.abs("out.txt")); string(data) != "b\na\n" {
+		t.Errorf("unexpected product %q", data)
+	}
+}
+
+func TestHashTreeReusesUnchanged(t *testing.T) {
+	p, err := load(t, `
+stages:
+  - name: write
+    command: exec
+    args: [sh, -c, "echo a > out.txt"]
+    outputs: [out.txt]
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileName := p.abs("out.txt")
+	if err = os.WriteFile(fileName, []byte("a\n"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewRunner(p, nil, "", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.hashes[fileName] = fileHash{Size: info.Size(), ModTime: info.ModTime(), Sum: "cached"}
+	if sum, err := r.hashTree(fileName); err != nil || sum != "cached" {
+		t.Errorf("expected the cached hash, found %s, %v", sum, err)
+	}
+	r.hashes[fileName] = fileHash{Size: info.Size() + 1, ModTime: info.ModTime(), Sum: "cached"}
+	if sum, err := r.hashTree(fileName); err != nil || sum == "cached" || r.hashes[fileName].Sum != sum {
+		t.Errorf("expected a new hash, found %s, %v", sum, err)
+	}
+}
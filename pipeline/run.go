@@ -0,0 +1,393 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nordicsense/landsat/logging"
+	"github.com/nordicsense/landsat/provenance"
+)
+
+// Task statuses in the run report.
+const (
+	StatusDone    = "done"
+	StatusSkipped = "up-to-date"
+	StatusFailed  = "failed"
+)
+
+// outputOption names the option of the output directory, which is not an input of the fingerprint.
+const outputOption = "output"
+
+// maxLog limits the command output kept in the report of failed tasks.
+const maxLog = 4096
+
+// Products returns the files a landsat command writes for the input, given its arguments and options.
+type Products func(input string, args []string, options map[string]string) []string
+
+// Report describes a pipeline run.
+type Report struct {
+	Pipeline string       `json:"pipeline"`
+	Version  string       `json:"version"`
+	Started  time.Time    `json:"started"`
+	Finished time.Time    `json:"finished"`
+	Tasks    []TaskReport `json:"tasks"`
+}
+
+// TaskReport describes the run of a command on one input.
+type TaskReport struct {
+	Stage    string    `json:"stage"`
+	Input    string    `json:"input,omitempty"`
+	Command  []string  `json:"command"`
+	Outputs  []string  `json:"outputs"`
+	Status   string    `json:"status"`
+	Started  time.Time `json:"started"`
+	Duration float64   `json:"duration"`
+	Error    string    `json:"error,omitempty"`
+	Log      string    `json:"log,omitempty"`
+}
+
+// entry is the state of a task product: the fingerprint of command and inputs it was made with and the
+// content hashes of its outputs.
+type entry struct {
+	Fingerprint string            `json:"fingerprint"`
+	Outputs     map[string]string `json:"outputs"`
+}
+
+// fileHash is the content hash of a file together with the size and modification time it was computed for.
+type fileHash struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Sum     string    `json:"sum"`
+}
+
+// state is the content of the state file: the task products by stage and input and the content hashes of
+// files by path, which are reused as long as the files keep their size and modification time.
+type state struct {
+	Tasks  map[string]entry    `json:"tasks"`
+	Hashes map[string]fileHash `json:"hashes"`
+}
+
+// Runner runs the stages of a pipeline in dependency order, the tasks of a stage in parallel, each as a
+// separate process of the landsat executable.
+type Runner struct {
+	p          *Pipeline
+	products   map[string]Products
+	executable string
+	verbose    bool
+
+	mu      sync.Mutex
+	state   map[string]entry
+	hashes  map[string]fileHash
+	outputs map[string][]string
+}
+
+// NewRunner creates a runner of the pipeline executing commands with the executable, usually the running
+// landsat binary. Products must be known for every command, other than exec, of stages without output globs.
+func NewRunner(p *Pipeline, products map[string]Products, executable string, verbose bool) (*Runner, error) {
+	for _, s := range p.Stages {
+		if _, ok := products[s.Command]; !ok && len(s.Outputs) == 0 {
+			if s.Command == Exec {
+				return nil, fmt.Errorf("stage %s runs an external program and needs output globs", s.Name)
+			}
+			return nil, fmt.Errorf("stage %s: unknown products of command %s, declare output globs", s.Name, s.Command)
+		}
+		if s.Command == Exec && len(s.Args) == 0 {
+			return nil, fmt.Errorf("stage %s: no program to execute", s.Name)
+		}
+	}
+	return &Runner{
+		p:          p,
+		products:   products,
+		executable: executable,
+		verbose:    verbose,
+		state:      make(map[string]entry),
+		hashes:     make(map[string]fileHash),
+		outputs:    make(map[string][]string),
+	}, nil
+}
+
+// Run runs all stages not up-to-date and writes the run report, also when a stage fails. Stages following a
+// failed one are not run.
+func (r *Runner) Run() (*Report, error) {
+	stages, err := r.p.order()
+	if err != nil {
+		return nil, err
+	}
+	if err = r.loadState(); err != nil {
+		return nil, err
+	}
+	report := &Report{Pipeline: r.p.dir, Version: provenance.Version(), Started: time.Now()}
+	for _, s := range stages {
+		tasks, err := r.runStage(s)
+		report.Tasks = append(report.Tasks, tasks...)
+		if err == nil {
+			err = r.saveState()
+		}
+		if err != nil {
+			report.Finished = time.Now()
+			_ = r.writeReport(report)
+			return report, err
+		}
+	}
+	report.Finished = time.Now()
+	return report, r.writeReport(report)
+}
+
+func (r *Runner) runStage(s *Stage) ([]TaskReport, error) {
+	inputs := []string{""}
+	if s.PerInput() {
+		var err error
+		if inputs, err = r.inputs(s); err != nil {
+			return nil, err
+		}
+	}
+	if r.verbose {
+		logging.Info("stage started", "stage", s.Name, "tasks", len(inputs))
+	}
+
+	res := make([]TaskReport, len(inputs))
+	sem := make(chan struct{}, r.p.Workers)
+	var wg sync.WaitGroup
+	for i, input := range inputs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, input string) {
+			defer func() { <-sem; wg.Done() }()
+			res[i] = r.runTask(s, input)
+		}(i, input)
+	}
+	wg.Wait()
+
+	var failed []string
+	for _, t := range res {
+		if t.Status == StatusFailed {
+			failed = append(failed, t.Input)
+		}
+		if len(s.Outputs) == 0 {
+			r.outputs[s.Name] = append(r.outputs[s.Name], t.Outputs...)
+		}
+	}
+	if len(failed) > 0 {
+		return res, fmt.Errorf("stage %s failed for %d task(s)", s.Name, len(failed))
+	}
+	if len(s.Outputs) > 0 {
+		outputs, err := r.p.glob(s.Outputs)
+		if err != nil {
+			return res, err
+		}
+		r.outputs[s.Name] = outputs
+	}
+	return res, nil
+}
+
+// inputs returns the files processed by the tasks of the stage.
+func (r *Runner) inputs(s *Stage) ([]string, error) {
+	if len(s.Inputs) > 0 {
+		return r.p.glob(s.Inputs)
+	}
+	var res []string
+	for _, from := range s.From {
+		res = append(res, r.outputs[from]...)
+	}
+	return res, nil
+}
+
+func (r *Runner) runTask(s *Stage, input string) (res TaskReport) {
+	cmdLine := s.commandLine(input)
+	res = TaskReport{Stage: s.Name, Input: input, Command: cmdLine, Started: time.Now()}
+	defer func() { res.Duration = time.Since(res.Started).Seconds() }()
+	fail := func(err error) TaskReport {
+		res.Status = StatusFailed
+		res.Error = err.Error()
+		return res
+	}
+
+	var expected []string
+	if products, ok := r.products[s.Command]; ok && len(s.Outputs) == 0 {
+		for _, output := range products(input, s.Args, s.Options) {
+			expected = append(expected, r.p.abs(output))
+		}
+	}
+
+	key := s.Name + ":" + input
+	fingerprint, err := r.fingerprint(cmdLine)
+	if err != nil {
+		return fail(err)
+	}
+	r.mu.Lock()
+	previous, known := r.state[key]
+	r.mu.Unlock()
+	if known && previous.Fingerprint == fingerprint && r.upToDate(previous.Outputs) {
+		res.Status = StatusSkipped
+		res.Outputs = sortedKeys(previous.Outputs)
+		if r.verbose {
+			logging.Info("up-to-date", "stage", s.Name, "input", input)
+		}
+		return res
+	}
+
+	if r.verbose {
+		logging.Info("running", "stage", s.Name, "command", strings.Join(cmdLine, " "))
+	}
+	var cmd *exec.Cmd
+	if s.Command == Exec {
+		cmd = exec.Command(cmdLine[0], cmdLine[1:]...)
+	} else {
+		cmd = exec.Command(r.executable, cmdLine...)
+	}
+	cmd.Dir = r.p.dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if len(out) > maxLog {
+			out = out[len(out)-maxLog:]
+		}
+		res.Log = string(out)
+		return fail(err)
+	}
+
+	if expected == nil {
+		if expected, err = r.p.glob(s.Outputs); err != nil {
+			return fail(err)
+		}
+	}
+	hashes := make(map[string]string)
+	for _, output := range expected {
+		if hashes[output], err = r.hashTree(output); err != nil {
+			return fail(fmt.Errorf("missing product: %v", err))
+		}
+	}
+	r.mu.Lock()
+	r.state[key] = entry{Fingerprint: fingerprint, Outputs: hashes}
+	r.mu.Unlock()
+	res.Status = StatusDone
+	res.Outputs = expected
+	return res
+}
+
+// fingerprint hashes the command line together with the content of all files and directories it names, other
+// than the output directory, and the tool version. Content hashes of files unchanged since the last run are
+// taken from the state.
+func (r *Runner) fingerprint(cmdLine []string) (string, error) {
+	h := sha256.New()
+	_, _ = io.WriteString(h, provenance.Version()+"\n")
+	for _, arg := range cmdLine {
+		_, _ = io.WriteString(h, arg+"\n")
+		value := arg
+		if strings.HasPrefix(arg, "--") {
+			kv := strings.SplitN(strings.TrimPrefix(arg, "--"), "=", 2)
+			if len(kv) < 2 || kv[0] == outputOption {
+				continue
+			}
+			value = kv[1]
+		}
+		// change takes comma-separated lists of files
+		for _, name := range strings.Split(value, ",") {
+			if name == "" {
+				continue
+			}
+			if _, err := os.Stat(r.p.abs(name)); err != nil {
+				continue
+			}
+			sum, err := r.hashTree(r.p.abs(name))
+			if err != nil {
+				return "", err
+			}
+			_, _ = io.WriteString(h, sum+"\n")
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	var res []string
+	for k := range m {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (r *Runner) upToDate(outputs map[string]string) bool {
+	if len(outputs) == 0 {
+		return false
+	}
+	for output, sum := range outputs {
+		if actual, err := r.hashTree(output); err != nil || actual != sum {
+			return false
+		}
+	}
+	return true
+}
+
+// hashTree computes the content hash of a file or directory as provenance.HashTree does, hashing only the files
+// changed in size or modification time since their hash was recorded.
+func (r *Runner) hashTree(root string) (string, error) {
+	return provenance.HashTreeFunc(root, func(fileName string, info fs.FileInfo) (string, error) {
+		r.mu.Lock()
+		cached, ok := r.hashes[fileName]
+		r.mu.Unlock()
+		if ok && cached.Size == info.Size() && cached.ModTime.Equal(info.ModTime()) {
+			return cached.Sum, nil
+		}
+		sum, err := provenance.HashFile(fileName)
+		if err != nil {
+			return "", err
+		}
+		r.mu.Lock()
+		r.hashes[fileName] = fileHash{Size: info.Size(), ModTime: info.ModTime(), Sum: sum}
+		r.mu.Unlock()
+		return sum, nil
+	})
+}
+
+// loadState reads the state of the last run, leaving out the hashes of files removed since.
+func (r *Runner) loadState() error {
+	data, err := os.ReadFile(r.p.abs(r.p.State))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var st state
+	if err = json.Unmarshal(data, &st); err != nil {
+		return err
+	}
+	for key, e := range st.Tasks {
+		r.state[key] = e
+	}
+	for fileName, h := range st.Hashes {
+		if _, err := os.Stat(fileName); err == nil {
+			r.hashes[fileName] = h
+		}
+	}
+	return nil
+}
+
+func (r *Runner) saveState() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	data, err := json.MarshalIndent(state{Tasks: r.state, Hashes: r.hashes}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.p.abs(r.p.State), data, 0640)
+}
+
+func (r *Runner) writeReport(report *Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.p.abs(r.p.Report), data, 0640)
+}
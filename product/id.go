@@ -0,0 +1,83 @@
+// Package product identifies Landsat Collection products by their product identifiers, which prefix the names
+// of all files of a scene, e.g. LT05_L2SP_190011_20090725_20200827_02_T1_SR_B1.TIF.
+package product
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const dateFormat = "20060102"
+
+// idRe matches product identifiers LXSS_LLLL_PPPRRR_YYYYMMDD_yyyymmdd_CC_TX, the processing date, collection and
+// tier being optional in the short form identifying the acquisition used in field data.
+var idRe = regexp.MustCompile(`L([COTEM])(\d{2})_(L[12][A-Z]{2})_(\d{3})(\d{3})_(\d{8})(?:_(\d{8})_(\d{2})_(T1|T2|RT))?`)
+
+// ID is a parsed Landsat product identifier.
+type ID struct {
+	// Sensor is C for OLI/TIRS, O for OLI only, T for TM (or TIRS only), E for ETM+ and M for MSS.
+	Sensor string
+	// Satellite is the Landsat series number, e.g. 5 for Landsat 5.
+	Satellite int
+	// Level is the processing correction level, e.g. L1TP or L2SP.
+	Level     string
+	Path, Row int
+	Acquired  time.Time
+	// Processed, Collection and Tier are zero in short identifiers.
+	Processed  time.Time
+	Collection int
+	Tier       string
+}
+
+// ParseID parses a product identifier, full or short, or the name of a file prefixed by one.
+func ParseID(name string) (ID, error) {
+	var res ID
+	m := idRe.FindStringSubmatch(path.Base(name))
+	if m == nil {
+		return res, fmt.Errorf("no Landsat product identifier in %s", name)
+	}
+	res.Sensor = m[1]
+	res.Satellite, _ = strconv.Atoi(m[2])
+	res.Level = m[3]
+	res.Path, _ = strconv.Atoi(m[4])
+	res.Row, _ = strconv.Atoi(m[5])
+	var err error
+	if res.Acquired, err = time.Parse(dateFormat, m[6]); err != nil {
+		return res, fmt.Errorf("invalid acquisition date in %s: %v", name, err)
+	}
+	if m[7] != "" {
+		if res.Processed, err = time.Parse(dateFormat, m[7]); err != nil {
+			return res, fmt.Errorf("invalid processing date in %s: %v", name, err)
+		}
+		res.Collection, _ = strconv.Atoi(m[8])
+		res.Tier = m[9]
+	}
+	return res, nil
+}
+
+// Short returns the short identifier of the acquisition, e.g. LT05_L1TP_190011_20090725.
+func (id ID) Short() string {
+	return fmt.Sprintf("L%s%02d_%s_%s_%s", id.Sensor, id.Satellite, id.Level, id.PathRow(), id.Acquired.Format(dateFormat))
+}
+
+// String returns the product identifier, the short one if processing date, collection and tier are unknown.
+func (id ID) String() string {
+	if id.Processed.IsZero() {
+		return id.Short()
+	}
+	return fmt.Sprintf("%s_%s_%02d_%s", id.Short(), id.Processed.Format(dateFormat), id.Collection, id.Tier)
+}
+
+// PathRow returns the WRS-2 path and row, e.g. 188012.
+func (id ID) PathRow() string {
+	return fmt.Sprintf("%03d%03d", id.Path, id.Row)
+}
+
+// SameScene tells whether both identify the same acquisition, whatever the processing level and date.
+func (id ID) SameScene(other ID) bool {
+	return id.Sensor == other.Sensor && id.Satellite == other.Satellite && id.Path == other.Path &&
+		id.Row == other.Row && id.Acquired.Equal(other.Acquired)
+}
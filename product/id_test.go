@@ -0,0 +1,34 @@
+package product
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseID(t *testing.T) {
+	id, err := ParseID("/data/LT05_L2SP_190011_20090725_20200827_02_T1_SR_B1.TIF")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := ID{Sensor: "T", Satellite: 5, Level: "L2SP", Path: 190, Row: 11,
+		Acquired:  time.Date(2009, 7, 25, 0, 0, 0, 0, time.UTC),
+		Processed: time.Date(2020, 8, 27, 0, 0, 0, 0, time.UTC), Collection: 2, Tier: "T1"}
+	if id != expected {
+		t.Errorf("expected %+v, found %+v", expected, id)
+	}
+	if id.String() != "LT05_L2SP_190011_20090725_20200827_02_T1" {
+		t.Errorf("unexpected identifier %s", id)
+	}
+
+	short, err := ParseID("LT05_L1TP_190011_20090725")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if short.String() != "LT05_L1TP_190011_20090725" || !short.SameScene(id) {
+		t.Errorf("expected the same scene as %s, found %s", id, short)
+	}
+
+	if _, err = ParseID("study-area.wkt"); err == nil {
+		t.Error("expected an error")
+	}
+}
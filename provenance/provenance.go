@@ -0,0 +1,254 @@
+package provenance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"time"
+
+	"github.com/nordicsense/gdal"
+	"github.com/nordicsense/landsat/dataset"
+	landsatio "github.com/nordicsense/landsat/io"
+)
+
+const (
+	// MetadataKey is the dataset-level metadata item holding the JSON encoded record.
+	MetadataKey = dataset.ProvenanceKey
+	// SidecarSuffix is appended to the product file name to name the JSON sidecar.
+	SidecarSuffix = ".provenance.json"
+
+	tool = "landsat"
+)
+
+// File identifies an input by path and content hash.
+type File struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+}
+
+// Record describes how a product was made: by which tool version, with which command and options and from
+// which inputs.
+type Record struct {
+	Tool     string            `json:"tool"`
+	Version  string            `json:"version"`
+	Command  string            `json:"command"`
+	Args     []string          `json:"args,omitempty"`
+	Options  map[string]string `json:"options,omitempty"`
+	Inputs   []File            `json:"inputs,omitempty"`
+	Model    *File             `json:"model,omitempty"`
+	Legend   map[int]string    `json:"legend,omitempty"`
+	Started  time.Time         `json:"started"`
+	Finished time.Time         `json:"finished"`
+}
+
+// New starts a record for a command invocation, the start time is taken now.
+func New(command string, args []string, options map[string]string) *Record {
+	opts := make(map[string]string)
+	for k, v := range options {
+		opts[k] = v
+	}
+	return &Record{
+		Tool:    tool,
+		Version: Version(),
+		Command: command,
+		Args:    append([]string{}, args...),
+		Options: opts,
+		Started: time.Now().UTC(),
+	}
+}
+
+// Copy returns a deep copy of the record so that a common command record can be specialised per product.
+func (r *Record) Copy() *Record {
+	res := *r
+	res.Args = append([]string{}, r.Args...)
+	res.Inputs = append([]File{}, r.Inputs...)
+	res.Options = make(map[string]string)
+	for k, v := range r.Options {
+		res.Options[k] = v
+	}
+	if r.Model != nil {
+		m := *r.Model
+		res.Model = &m
+	}
+	if r.Legend != nil {
+		res.Legend = make(map[int]string)
+		for k, v := range r.Legend {
+			res.Legend[k] = v
+		}
+	}
+	return &res
+}
+
+// AddInput hashes an input file and adds it to the record.
+func (r *Record) AddInput(fileName string) error {
+	sum, err := HashFile(fileName)
+	if err != nil {
+		return err
+	}
+	r.Inputs = append(r.Inputs, File{Path: fileName, SHA256: sum})
+	return nil
+}
+
+// SetModel hashes a model file or directory and adds it to the record.
+func (r *Record) SetModel(name string) error {
+	sum, err := HashTree(name)
+	if err != nil {
+		return err
+	}
+	r.Model = &File{Path: name, SHA256: sum}
+	return nil
+}
+
+// SetLegend records the meaning of the raster values of the product.
+func (r *Record) SetLegend(legend map[int]string) {
+	r.Legend = make(map[int]string)
+	for k, v := range legend {
+		r.Legend[k] = v
+	}
+}
+
+// Attach stamps the finish time, embeds the record into the product metadata and, if requested, writes it
+// into a JSON sidecar next to the product.
+func (r *Record) Attach(fileName string, sidecar bool) error {
+	r.Finished = time.Now().UTC()
+	bytes, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	ds, err := gdal.Open(fileName, gdal.Update)
+	if err != nil {
+		return err
+	}
+	err = ds.SetMetadataItem(MetadataKey, string(bytes), "")
+	ds.Close()
+	if err != nil || !sidecar {
+		return err
+	}
+	return r.WriteSidecar(fileName)
+}
+
+// WriteSidecar writes the record into a JSON sidecar next to the product, local or remote.
+func (r *Record) WriteSidecar(fileName string) error {
+	bytes, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return landsatio.WriteFile(fileName+SidecarSuffix, bytes)
+}
+
+// Paths returns the paths of the inputs and of the model, if any.
+func (r *Record) Paths() []string {
+	var res []string
+	for _, f := range r.Inputs {
+		res = append(res, f.Path)
+	}
+	if r.Model != nil {
+		res = append(res, r.Model.Path)
+	}
+	return res
+}
+
+// Read decodes the record embedded into a product, returns nil if the product carries none.
+func Read(fileName string) (*Record, error) {
+	ds, err := gdal.Open(fileName, gdal.ReadOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer ds.Close()
+	value := ds.MetadataItem(MetadataKey, "")
+	if value == "" {
+		return nil, nil
+	}
+	res := &Record{}
+	return res, json.Unmarshal([]byte(value), res)
+}
+
+// Version reports the module version and VCS revision of the running binary.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	res := info.Main.Version
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			res += " " + s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				res += "+dirty"
+			}
+		}
+	}
+	return res
+}
+
+// HashFile computes the hex encoded SHA-256 sum of the content of a local file or remote object.
+func HashFile(fileName string) (string, error) {
+	f, err := landsatio.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashTree computes the SHA-256 sum of a file or of all files under a directory (in lexical order, including
+// their relative paths) such as a saved Tensorflow model.
+func HashTree(root string) (string, error) {
+	return HashTreeFunc(root, func(fileName string, _ fs.FileInfo) (string, error) {
+		return HashFile(fileName)
+	})
+}
+
+// HashTreeFunc computes the sum as HashTree does, with the sums of the files computed by the function, e.g. to
+// reuse the sums of files unchanged since they were last hashed.
+func HashTreeFunc(root string, hashFile func(fileName string, info fs.FileInfo) (string, error)) (string, error) {
+	info, err := os.Stat(root)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() {
+		return hashFile(root, info)
+	}
+	var (
+		fNames []string
+		infos  = make(map[string]fs.FileInfo)
+	)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		fNames = append(fNames, p)
+		infos[p] = info
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(fNames)
+	h := sha256.New()
+	for _, fName := range fNames {
+		rel, _ := filepath.Rel(root, fName)
+		sum, err := hashFile(fName, infos[fName])
+		if err != nil {
+			return "", err
+		}
+		_, _ = io.WriteString(h, rel+" "+sum+"\n")
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
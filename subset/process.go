@@ -0,0 +1,70 @@
+package subset
+
+import (
+	"fmt"
+
+	"github.com/nordicsense/landsat/dataset"
+	"github.com/nordicsense/landsat/logging"
+)
+
+// Process crops all bands of an image to the pixel window clipped to the image. Band values, nodata and
+// metadata are kept and the transform shifted to the window origin.
+func Process(inputTiff, outputTiff string, window dataset.Box, skip, verbose bool, output dataset.OutputOptions) error {
+	if skip && dataset.UpToDate(outputTiff, inputTiff) {
+		return nil
+	}
+
+	r, err := dataset.OpenMultiBand(inputTiff)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	ip := r.ImageParams()
+	if window = ip.Clip(window); window[2] == 0 || window[3] == 0 {
+		return fmt.Errorf("window %v does not overlap with %s", window, inputTiff)
+	}
+	wip := ip.Subset(window)
+
+	w, err := dataset.NewMultiBand(outputTiff, dataset.GTiff, r.Bands(), wip, output.CreationOptions(wip.DataType())...)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err = w.SetDatasetParams(r.DatasetParams().Inheritable()); err != nil {
+		return err
+	}
+	for band := 1; band <= r.Bands(); band++ {
+		if err = w.Writer(band).SetRasterParams(r.Reader(band).RasterParams()); err != nil {
+			return err
+		}
+	}
+
+	nx := window[2]
+	ny := window[3]
+
+	bar := logging.NewProgress("subset", 0, int64(ny))
+	if verbose {
+		bar.Start()
+	}
+
+	row := make([]float64, nx)
+	for y := 0; y < ny; y++ {
+		for band := 1; band <= r.Bands(); band++ {
+			if err = dataset.ReadTyped(r.Reader(band), window[0], window[1]+y, dataset.Box{0, 0, nx, 1}, row); err != nil {
+				return err
+			}
+			if err = dataset.WriteTyped(w.Writer(band), 0, y, dataset.Box{0, 0, nx, 1}, row); err != nil {
+				return err
+			}
+		}
+		if verbose {
+			bar.Advance(1)
+		}
+	}
+	if verbose {
+		bar.Stop()
+	}
+	return nil
+}
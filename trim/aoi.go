@@ -0,0 +1,224 @@
+package trim
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/nordicsense/gdal"
+	"github.com/nordicsense/landsat/dataset"
+	"github.com/nordicsense/landsat/io"
+)
+
+// AOI is an area of interest of one or more polygons with holes in the projected coordinates of the images.
+// Pixels are inside when their centre is within an odd number of the rings of any polygon, so that overlapping
+// polygons do not cut holes into each other.
+type AOI struct {
+	polygons [][][]point
+}
+
+type point struct {
+	x, y float64
+}
+
+// edgePoints is the number of points per edge of bounding boxes, which are curved in other projections.
+const edgePoints = 16
+
+// BBox returns the area of interest of a bounding box given by its west, south, east and north bounds in the
+// coordinate reference system of the EPSG code, e.g. degrees for 4326, transformed into the projection of the
+// images.
+func BBox(west, south, east, north float64, epsg int, projection string) (*AOI, error) {
+	if west >= east || south >= north {
+		return nil, fmt.Errorf("empty bounding box %g,%g,%g,%g", west, south, east, north)
+	}
+	var coords []string
+	corners := []point{{west, south}, {east, south}, {east, north}, {west, north}}
+	for i, p := range corners {
+		q := corners[(i+1)%len(corners)]
+		for j := 0; j < edgePoints; j++ {
+			f := float64(j) / edgePoints
+			coords = append(coords, fmt.Sprintf("%f %f", p.x+f*(q.x-p.x), p.y+f*(q.y-p.y)))
+		}
+	}
+	coords = append(coords, coords[0])
+	return fromWKT("POLYGON(("+strings.Join(coords, ",")+"))", epsg, projection)
+}
+
+// ReadAOI reads the polygons of a GeoJSON or another OGR vector file, or of a file with a single WKT geometry
+// (extension .wkt), and transforms them into the projection of the images. Vector files carry their own
+// coordinate reference system, WKT files are in the one of the EPSG code. Files may be remote.
+func ReadAOI(fileName string, epsg int, projection string) (*AOI, error) {
+	if strings.ToLower(path.Ext(fileName)) == ".wkt" {
+		data, err := io.ReadFile(path.Dir(fileName), path.Base(fileName))
+		if err != nil {
+			return nil, err
+		}
+		return fromWKT(strings.TrimSpace(string(data)), epsg, projection)
+	}
+
+	var geoms []gdal.Geometry
+	defer func() {
+		for _, g := range geoms {
+			g.Destroy()
+		}
+	}()
+	ds := gdal.OpenDataSource(fileName, 0)
+	if ds == (gdal.DataSource{}) {
+		return nil, fmt.Errorf("failed to open %s", fileName)
+	}
+	defer ds.Destroy()
+	for i := 0; i < ds.LayerCount(); i++ {
+		layer := ds.LayerByIndex(i)
+		layer.ResetReading()
+		for feature := layer.NextFeature(); feature != nil; feature = layer.NextFeature() {
+			// features without geometry are left out
+			if g := feature.Geometry(); !g.IsNull() {
+				geoms = append(geoms, g.Clone())
+			}
+			feature.Destroy()
+		}
+	}
+	res, err := transformed(geoms, projection)
+	if err == nil && len(res.polygons) == 0 {
+		err = fmt.Errorf("no polygons found in %s", fileName)
+	}
+	return res, err
+}
+
+func fromWKT(wkt string, epsg int, projection string) (*AOI, error) {
+	from := gdal.CreateSpatialReference("")
+	defer from.Destroy()
+	if err := from.FromEPSG(epsg); err != nil {
+		return nil, err
+	}
+	g, err := gdal.CreateFromWKT(wkt, from)
+	if err != nil {
+		return nil, err
+	}
+	defer g.Destroy()
+	res, err := transformed([]gdal.Geometry{g}, projection)
+	if err == nil && len(res.polygons) == 0 {
+		err = fmt.Errorf("no polygons found in %s", wkt)
+	}
+	return res, err
+}
+
+func transformed(geoms []gdal.Geometry, projection string) (*AOI, error) {
+	to := gdal.CreateSpatialReference(projection)
+	defer to.Destroy()
+	res := &AOI{}
+	for _, g := range geoms {
+		if err := g.TransformTo(to); err != nil {
+			return nil, err
+		}
+		res.add(g)
+	}
+	return res, nil
+}
+
+// add collects the rings of polygons, also within multipolygons and collections; other geometries are ignored.
+func (a *AOI) add(g gdal.Geometry) {
+	switch g.Type() {
+	case gdal.GT_Polygon, gdal.GT_Polygon25D:
+		var rings [][]point
+		for i := 0; i < g.GeometryCount(); i++ {
+			r := g.Geometry(i)
+			ring := make([]point, r.PointCount())
+			for j := range ring {
+				ring[j].x, ring[j].y, _ = r.Point(j)
+			}
+			rings = append(rings, ring)
+		}
+		if len(rings) > 0 {
+			a.polygons = append(a.polygons, rings)
+		}
+	case gdal.GT_MultiPolygon, gdal.GT_MultiPolygon25D, gdal.GT_GeometryCollection, gdal.GT_GeometryCollection25D:
+		for i := 0; i < g.GeometryCount(); i++ {
+			a.add(g.Geometry(i))
+		}
+	}
+}
+
+// rings returns the rings of all polygons.
+func (a *AOI) rings() [][]point {
+	var res [][]point
+	for _, rings := range a.polygons {
+		res = append(res, rings...)
+	}
+	return res
+}
+
+// Bounds returns the top-left and bottom-right corners of the bounding box in projected coordinates.
+func (a *AOI) Bounds() (tl, br dataset.LatLon) {
+	tl = dataset.LatLon{math.Inf(-1), math.Inf(1)}
+	br = dataset.LatLon{math.Inf(1), math.Inf(-1)}
+	for _, ring := range a.rings() {
+		for _, p := range ring {
+			tl = dataset.LatLon{math.Max(tl[0], p.y), math.Min(tl[1], p.x)}
+			br = dataset.LatLon{math.Min(br[0], p.y), math.Max(br[1], p.x)}
+		}
+	}
+	return tl, br
+}
+
+// Window returns the pixel box of the image covering the area of interest, clipped to the image.
+func (a *AOI) Window(ip *dataset.ImageParams) dataset.Box {
+	at := ip.Transform()
+	minx, miny := math.Inf(1), math.Inf(1)
+	maxx, maxy := math.Inf(-1), math.Inf(-1)
+	for _, ring := range a.rings() {
+		for _, p := range ring {
+			x := (p.x - at[0]) / at[1]
+			y := (p.y - at[3]) / at[5]
+			minx, maxx = math.Min(minx, x), math.Max(maxx, x)
+			miny, maxy = math.Min(miny, y), math.Max(maxy, y)
+		}
+	}
+	x0 := clip(int(math.Floor(minx)), ip.XSize())
+	y0 := clip(int(math.Floor(miny)), ip.YSize())
+	x1 := clip(int(math.Ceil(maxx)), ip.XSize())
+	y1 := clip(int(math.Ceil(maxy)), ip.YSize())
+	return dataset.Box{x0, y0, x1 - x0, y1 - y0}
+}
+
+func clip(v, size int) int {
+	if v < 0 {
+		return 0
+	}
+	if v > size {
+		return size
+	}
+	return v
+}
+
+// Row marks the pixels of image row y inside the area of interest, inside being of the length of the row.
+func (a *AOI) Row(at dataset.AffineTransform, y int, inside []bool) {
+	for x := range inside {
+		inside[x] = false
+	}
+	// crossings of the line through the pixel centres with the ring edges of every polygon in pixel coordinates
+	cy := at[3] + (float64(y)+.5)*at[5]
+	var xs []float64
+	for _, rings := range a.polygons {
+		xs = xs[:0]
+		for _, ring := range rings {
+			for i := range ring {
+				p, q := ring[i], ring[(i+1)%len(ring)]
+				if (p.y > cy) != (q.y > cy) {
+					cx := p.x + (cy-p.y)*(q.x-p.x)/(q.y-p.y)
+					xs = append(xs, (cx-at[0])/at[1])
+				}
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			from := clip(int(math.Ceil(xs[i]-.5)), len(inside))
+			to := clip(int(math.Ceil(xs[i+1]-.5)), len(inside))
+			for x := from; x < to; x++ {
+				inside[x] = true
+			}
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package trim
+
+import (
+	"testing"
+
+	"github.com/nordicsense/landsat/dataset"
+)
+
+func mask(aoi *AOI, at dataset.AffineTransform, nx, ny int) []string {
+	var res []string
+	inside := make([]bool, nx)
+	for y := 0; y < ny; y++ {
+		aoi.Row(at, y, inside)
+		row := ""
+		for _, in := range inside {
+			if in {
+				row += "#"
+			} else {
+				row += "."
+			}
+		}
+		res = append(res, row)
+	}
+	return res
+}
+
+func TestRow(t *testing.T) {
+	// 10m pixels, north up, origin at (1000, 2000)
+	at := dataset.AffineTransform{1000, 10, 0, 2000, 0, -10}
+	square := func(x0, y0, x1, y1 float64) []point {
+		return []point{{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}}
+	}
+	tests := []struct {
+		name     string
+		aoi      *AOI
+		expected []string
+	}{
+		{"square with hole", &AOI{polygons: [][][]point{{
+			square(1010, 1990, 1060, 1940),
+			square(1030, 1970, 1040, 1960),
+		}}}, []string{
+			".......",
+			".#####.",
+			".#####.",
+			".##.##.",
+			".#####.",
+			".#####.",
+			".......",
+		}},
+		{"multipolygon beyond edges", &AOI{polygons: [][][]point{
+			{square(950, 2050, 1020, 1978)},
+			{square(1045, 1955, 1100, 1900)},
+		}}, []string{
+			"##.....",
+			"##.....",
+			".......",
+			".......",
+			".......",
+			"....###",
+			"....###",
+		}},
+		{"overlapping polygons", &AOI{polygons: [][][]point{
+			{square(1010, 1990, 1050, 1950)},
+			{square(1030, 1970, 1060, 1940)},
+		}}, []string{
+			".......",
+			".####..",
+			".####..",
+			".#####.",
+			".#####.",
+			"...###.",
+			".......",
+		}},
+		{"diamond", &AOI{polygons: [][][]point{{
+			{{1030, 2000}, {1070, 1965}, {1040, 1930}, {1000, 1965}},
+		}}}, []string{
+			"...#...",
+			"..###..",
+			".#####.",
+			"#######",
+			".#####.",
+			"..###..",
+			"...#...",
+		}},
+	}
+	for _, tt := range tests {
+		actual := mask(tt.aoi, at, 7, 7)
+		for y := range actual {
+			if actual[y] != tt.expected[y] {
+				t.Errorf("%s: row %d: expected %s, found %s", tt.name, y, tt.expected[y], actual[y])
+			}
+		}
+	}
+}
+
+func TestWindowAndBounds(t *testing.T) {
+	at := dataset.AffineTransform{1000, 10, 0, 2000, 0, -10}
+	ip := dataset.ImageParamsBuilder(7, 7).Transform(at).Build()
+	aoi := &AOI{polygons: [][][]point{{{{1015, 1985}, {1100, 1985}, {1015, 1955}}}}}
+	if actual, expected := aoi.Window(ip), (dataset.Box{1, 1, 6, 4}); actual != expected {
+		t.Errorf("expected %v, found %v", expected, actual)
+	}
+	tl, br := aoi.Bounds()
+	if expected := (dataset.LatLon{1985, 1015}); tl != expected {
+		t.Errorf("expected %v, found %v", expected, tl)
+	}
+	if expected := (dataset.LatLon{1955, 1100}); br != expected {
+		t.Errorf("expected %v, found %v", expected, br)
+	}
+}
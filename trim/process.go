@@ -1,75 +1,93 @@
 package trim
 
 import (
-	"github.com/nordicsense/gdal"
+	"fmt"
+
 	"github.com/nordicsense/landsat/dataset"
-	"github.com/vardius/progress-go"
-	"os"
+	"github.com/nordicsense/landsat/logging"
 )
 
-var (
-	TL = dataset.LatLon{7668849, 479358} // sin
-	TR = dataset.LatLon{7620132, 592293}
-	BR = dataset.LatLon{7341672, 464008}
-	BL = dataset.LatLon{7392573, 358017}
-)
+// Options control what is kept of the image.
+type Options struct {
+	// Outside keeps the pixels outside of the area of interest instead of those inside.
+	Outside bool
+	// Crop reduces the output extent to the bounding box of the area of interest.
+	Crop bool
+}
 
-func Process(inputTiff, outputTiff string, skip, verbose bool, tl, tr, br, bl dataset.LatLon) error {
-	if _, err := os.Stat(outputTiff); skip && err == nil {
+// Process sets the pixels of all bands outside of the area of interest, or inside of it, to nodata. Images
+// without nodata value, such as class maps, get zero as nodata.
+func Process(inputTiff, outputTiff string, aoi *AOI, opts Options, skip, verbose bool, output dataset.OutputOptions) error {
+	if skip && dataset.UpToDate(outputTiff, inputTiff) {
 		return nil
 	}
+	if opts.Outside && opts.Crop {
+		return fmt.Errorf("cropping to the area of interest contradicts keeping its outside")
+	}
 
 	var (
 		err error
-		r   dataset.UniBandReader
+		r   dataset.MultiBandReader
 	)
 
-	if r, err = dataset.OpenUniBand(inputTiff); err != nil {
+	if r, err = dataset.OpenMultiBand(inputTiff); err != nil {
 		return err
 	}
 	defer r.Close()
 
 	ip := r.ImageParams()
-	isAboveTop := leftOf(tl, tr, ip)
-	isBelowBottom := leftOf(br, bl, ip)
-	isLeftOfLeft := leftOf(bl, tl, ip)
-	isRightOfRight := leftOf(tr, br, ip)
+	window := dataset.Box{0, 0, ip.XSize(), ip.YSize()}
+	if opts.Crop {
+		if window = aoi.Window(ip); window[2] == 0 || window[3] == 0 {
+			return fmt.Errorf("area of interest does not overlap with %s", inputTiff)
+		}
+	}
+	nx := window[2]
+	ny := window[3]
+	nodata, ok := ip.NaN()
+	if !ok {
+		nodata = 0.
+	}
 
-	w, err := dataset.NewUniBand(outputTiff, dataset.GTiff,
-		ip.ToBuilder().DataType(gdal.Byte).NaN(0.).Build(),
-		r.RasterParams().ToBuilder().Offset(0.).Scale(1.).Build(),
-		"compress=LZW", "predictor=2")
+	wip := ip.Subset(window).ToBuilder().NaN(nodata).Build()
+	wat := wip.Transform()
+	w, err := dataset.NewMultiBand(outputTiff, dataset.GTiff, r.Bands(), wip, output.CreationOptions(wip.DataType())...)
 	if err != nil {
 		return err
 	}
 	defer w.Close()
 
-	wds := w.BreakGlass().RasterBand(1)
-
-	nx := ip.XSize()
-	ny := ip.YSize()
+	if err = w.SetDatasetParams(r.DatasetParams().Inheritable()); err != nil {
+		return err
+	}
+	for band := 1; band <= r.Bands(); band++ {
+		if err = w.Writer(band).SetRasterParams(r.Reader(band).RasterParams()); err != nil {
+			return err
+		}
+	}
 
-	bar := progress.New(0, int64(ny))
+	bar := logging.NewProgress("trim", 0, int64(ny))
 	if verbose {
 		bar.Start()
 	}
 
-	row := make([]int8, nx)
-	// ugly performance workaround
-	ds := r.BreakGlass()
+	// raw values in float64 hold the values of all band types and NaN nodata of reflectance images
+	row := make([]float64, nx)
+	inside := make([]bool, nx)
 	for y := 0; y < ny; y++ {
-		if err = ds.RasterBand(1).IO(gdal.Read, 0, y, nx, 1, row, nx, 1, 0, 0); err != nil {
-			return err
-		}
-		for x := 0; x < nx; x++ {
-			if row[x] > 0 {
-				if isAboveTop(x, y) || isBelowBottom(x, y) || isLeftOfLeft(x, y) || isRightOfRight(x, y) {
-					row[x] = 0
+		aoi.Row(wat, y, inside)
+		for band := 1; band <= r.Bands(); band++ {
+			if err = dataset.ReadTyped(r.Reader(band), window[0], window[1]+y, dataset.Box{0, 0, nx, 1}, row); err != nil {
+				return err
+			}
+			for x := range row {
+				if inside[x] == opts.Outside {
+					row[x] = nodata
 				}
 			}
-		}
-		if err = wds.IO(gdal.Write, 0, y, nx, 1, row, nx, 1, 0, 0); err != nil {
-			return err
+			if err = dataset.WriteTyped(w.Writer(band), 0, y, dataset.Box{0, 0, nx, 1}, row); err != nil {
+				return err
+			}
 		}
 		if verbose {
 			bar.Advance(1)
@@ -80,14 +98,3 @@ func Process(inputTiff, outputTiff string, skip, verbose bool, tl, tr, br, bl da
 	}
 	return err
 }
-
-func leftOf(ll1, ll2 dataset.LatLon, ip *dataset.ImageParams) func(x, y int) bool {
-	x1, y1 := ip.Transform().LatLonSin2Pixels(ll1)
-	x2, y2 := ip.Transform().LatLonSin2Pixels(ll2)
-	y2y1 := float64(y2 - y1)
-	x2x1 := float64(x2 - x1)
-	return func(x, y int) bool {
-		v := float64(x-x1)*y2y1 - float64(y-y1)*x2x1
-		return v >= 0.0
-	}
-}
@@ -0,0 +1,203 @@
+package vector
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/nordicsense/gdal"
+	"github.com/nordicsense/landsat/dataset"
+	"github.com/nordicsense/landsat/io"
+)
+
+const (
+	memoryDriver = "Memory"
+	valueField   = "value"
+)
+
+// drivers maps output file extensions to OGR drivers.
+var drivers = map[string]string{
+	".geojson": "GeoJSON",
+	".json":    "GeoJSON",
+	".gpkg":    "GPKG",
+	".shp":     "ESRI Shapefile",
+}
+
+// Field is a polygon attribute derived from the raster value of the polygon.
+type Field struct {
+	Name string
+	// Type is either gdal.FT_Integer or gdal.FT_String.
+	Type  gdal.FieldType
+	Value func(v int) interface{}
+}
+
+// ClassFields returns the attributes of class maps: the class id and name.
+func ClassFields(legend map[int]string) []Field {
+	return []Field{
+		{Name: "class", Type: gdal.FT_Integer, Value: func(v int) interface{} { return v }},
+		{Name: "name", Type: gdal.FT_String, Value: func(v int) interface{} { return legend[v] }},
+	}
+}
+
+// TransitionFields returns the attributes of transitions maps: the from and to class ids and names and whether
+// the class changed.
+func TransitionFields(legend map[int]string, decode func(v int) (int, int)) []Field {
+	from := func(v int) int { f, _ := decode(v); return f }
+	to := func(v int) int { _, t := decode(v); return t }
+	return []Field{
+		{Name: "from", Type: gdal.FT_Integer, Value: func(v int) interface{} { return from(v) }},
+		{Name: "from_name", Type: gdal.FT_String, Value: func(v int) interface{} { return legend[from(v)] }},
+		{Name: "to", Type: gdal.FT_Integer, Value: func(v int) interface{} { return to(v) }},
+		{Name: "to_name", Type: gdal.FT_String, Value: func(v int) interface{} { return legend[to(v)] }},
+		{Name: "changed", Type: gdal.FT_Integer, Value: func(v int) interface{} {
+			if from(v) != to(v) {
+				return 1
+			}
+			return 0
+		}},
+	}
+}
+
+// Polygonize turns regions of equal value of an integer raster into polygons written to a GeoJSON, GeoPackage
+// or Shapefile selected by the output file extension. Zero, the nodata value of class maps, is not polygonized.
+// Polygons are optionally simplified with the tolerance in projection units, topology preserved.
+//
+// The output is written under a temporary name and renamed on success, so that existing outputs are complete
+// and skipped unless older than the input.
+func Polygonize(inputTiff, outputFile string, fields []Field, simplify float64, skip, verbose bool) error {
+	if skip && newer(outputFile, inputTiff) {
+		return nil
+	}
+	driverName, ok := drivers[strings.ToLower(path.Ext(outputFile))]
+	if !ok {
+		return fmt.Errorf("unsupported vector format %s", path.Ext(outputFile))
+	}
+	partial := dataset.PartialName(outputFile)
+	if err := polygonize(inputTiff, partial, outputFile, driverName, fields, simplify, verbose); err != nil {
+		return err
+	}
+	return commit(partial, outputFile)
+}
+
+func polygonize(inputTiff, partial, outputFile, driverName string, fields []Field, simplify float64, verbose bool) error {
+
+	r, err := dataset.OpenUniBand(inputTiff)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	srs := gdal.CreateSpatialReference(r.ImageParams().Projection())
+	defer srs.Destroy()
+
+	mem, ok := gdal.OGRDriverByName(memoryDriver).Create("", nil)
+	if !ok {
+		return fmt.Errorf("failed to create in-memory layer")
+	}
+	defer mem.Destroy()
+	raw := mem.CreateLayer("raw", srs, gdal.GT_Polygon, nil)
+	fd := gdal.CreateFieldDefinition(valueField, gdal.FT_Integer)
+	err = raw.CreateField(fd, false)
+	fd.Destroy()
+	if err != nil {
+		return err
+	}
+
+	// the dataset API has no access to GDAL algorithms
+	rb := r.BreakGlass().RasterBand(1)
+	progress := gdal.DummyProgress
+	if verbose {
+		progress = gdal.TermProgress
+	}
+	// the band masks itself: GDAL treats zero mask values as nodata
+	if err = rb.Polygonize(rb, raw, 0, nil, progress, nil); err != nil {
+		return err
+	}
+
+	// OGR drivers refuse to overwrite existing files
+	_ = os.Remove(partial)
+	out, ok := gdal.OGRDriverByName(driverName).Create(partial, nil)
+	if !ok {
+		return fmt.Errorf("failed to create %s", partial)
+	}
+	defer out.Destroy()
+	name := strings.TrimSuffix(path.Base(outputFile), path.Ext(outputFile))
+	layer := out.CreateLayer(name, srs, gdal.GT_Polygon, nil)
+	for _, f := range fields {
+		fd := gdal.CreateFieldDefinition(f.Name, f.Type)
+		err = layer.CreateField(fd, false)
+		fd.Destroy()
+		if err != nil {
+			return err
+		}
+	}
+
+	// transactions speed up GeoPackage writes, other formats do not support them
+	transaction := layer.StartTransaction() == nil
+	raw.ResetReading()
+	for feature := raw.NextFeature(); feature != nil; feature = raw.NextFeature() {
+		err = copyFeature(*feature, layer, fields, simplify)
+		feature.Destroy()
+		if err != nil {
+			return err
+		}
+	}
+	if transaction {
+		return layer.CommitTransaction()
+	}
+	return nil
+}
+
+// commit renames the temporary output to the output file, together with the files accompanying it, such as
+// those of Shapefiles.
+func commit(partial, outputFile string) error {
+	stem := strings.TrimSuffix(partial, path.Ext(partial))
+	siblings, err := filepath.Glob(stem + ".*")
+	if err != nil {
+		return err
+	}
+	for _, sibling := range siblings {
+		ext := strings.TrimPrefix(sibling, stem)
+		if err = os.Rename(sibling, strings.TrimSuffix(outputFile, path.Ext(outputFile))+ext); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newer tells whether the output exists and was modified after the input, which may be remote.
+func newer(outputFile, inputFile string) bool {
+	out, err := os.Stat(outputFile)
+	if err != nil {
+		return false
+	}
+	in, err := io.Stat(inputFile)
+	return err == nil && out.ModTime().After(in.ModTime())
+}
+
+func copyFeature(feature gdal.Feature, layer gdal.Layer, fields []Field, simplify float64) error {
+	v := feature.FieldAsInteger(0)
+	res := layer.Definition().Create()
+	defer res.Destroy()
+	geom := feature.Geometry()
+	if simplify > 0 {
+		geom = geom.SimplifyPreservingTopology(simplify)
+		defer geom.Destroy()
+	}
+	if err := res.SetGeometry(geom); err != nil {
+		return err
+	}
+	for i, f := range fields {
+		switch value := f.Value(v).(type) {
+		case int:
+			res.SetFieldInteger(i, value)
+		case string:
+			res.SetFieldString(i, value)
+		default:
+			return fmt.Errorf("unsupported value type %T of field %s", value, f.Name)
+		}
+	}
+	return layer.Create(res)
+}